@@ -4,13 +4,21 @@ import (
 	"errors"
 	"fmt"
 	"strings"
-
-	"github.com/xuri/excelize/v2"
 )
 
 type ICheckService interface {
 	Run() (totalRows int64, err error)
 	SetHeaderRule(*HeaderRuleValidate) ICheckService
+	// SetSheet 设置检查的主工作表，默认为第一个工作表，模板文件和导入文件使用相同的选择器
+	SetSheet(selector SheetSelector) ICheckService
+	// AddSheet 添加检查的工作表，主工作表默认为第一个工作表。添加的工作表只检查头部行是否和模板一致，总行数为所有工作表的行数之和
+	AddSheet(selector SheetSelector, skipRowNum int) ICheckService
+	// SetLogger 设置日志，默认为 slog.Default()，logger 为 nil 时使用默认日志。args 为每条日志附带的属性
+	SetLogger(logger Logger, args ...interface{}) ICheckService
+	// SetLocale 设置错误消息的语言，默认英文，错误消息与 ErrorMessage 常量一致，兼容比较 err.Error() 的调用方
+	SetLocale(locale Locale) ICheckService
+	// SetMessages 覆盖错误消息，key 见 MessageCheckTemplate 等常量
+	SetMessages(messages Messages) ICheckService
 }
 
 type checkService struct {
@@ -18,9 +26,20 @@ type checkService struct {
 	skipRowNum                          int
 	maxRowNum                           int64
 	headerRuleValidate                  IHeaderRuleValidate
+	logger                              Logger        // 日志
+	logAttrs                            []interface{} // 每条日志附带的属性
+	selector                            SheetSelector // 主工作表
+	sheets                              []checkSheet  // 添加的工作表
+	localizer                           localizer     // 错误消息的语言和覆盖的消息
+
+	tplFile, importFile *File
+}
 
-	tplFile, importFile         *File
-	tplFileRows, importFileRows *excelize.Rows
+// 检查的工作表
+type checkSheet struct {
+	selector           SheetSelector
+	skipRowNum         int
+	headerRuleValidate IHeaderRuleValidate
 }
 
 func NewCheckService(openTplFileFunc, openImportFileFUnc OpenFileFunc, skipRowNum int, maxRowNum int64) ICheckService {
@@ -29,6 +48,9 @@ func NewCheckService(openTplFileFunc, openImportFileFUnc OpenFileFunc, skipRowNu
 		openImportFileFUnc: openImportFileFUnc,
 		skipRowNum:         skipRowNum,
 		maxRowNum:          maxRowNum,
+		selector:           SheetByIndex(0),
+		logger:             defaultLogger(),
+		localizer:          localizer{locale: LocaleEnUS},
 	}
 }
 
@@ -43,6 +65,32 @@ const (
 	unknownError ErrorMessage = "unknown error: %s" // 未知错误
 )
 
+// 错误类型对应的消息 key
+var checkMessageKeys = map[ErrorMessage]string{
+	MaxRowNumError:        MessageCheckMaxRowNum,
+	TemplateError:         MessageCheckTemplate,
+	LargestRowNumberError: MessageCheckLargestRowNumber,
+	EmptyFile:             MessageCheckEmptyFile,
+	unknownError:          MessageCheckUnknown,
+}
+
+// CheckError 导入检查的错误，Message 为错误类型，例如 TemplateError，错误消息使用 SetLocale 设置的语言。
+// 未知错误可以通过 errors.As、errors.Is 获取原始错误
+type CheckError struct {
+	Message ErrorMessage // 错误类型
+	msg     string
+	cause   error
+}
+
+func (p *CheckError) Error() string {
+	return p.msg
+}
+
+// Unwrap 原始错误，只有未知错误存在
+func (p *CheckError) Unwrap() error {
+	return p.cause
+}
+
 func (p ErrorMessage) Error() error {
 	return errors.New(string(p))
 }
@@ -58,11 +106,64 @@ func (p ErrorMessage) Sprintf(e ...error) error {
 	return errors.New(fmt.Sprintf(string(p), a...))
 }
 
+// 导入检查的错误，errs 为错误消息的参数
+func (p *checkService) newError(message ErrorMessage, errs ...error) error {
+	args := []interface{}{}
+	var cause error
+	for i := 0; i < len(errs); i++ {
+		if errs[i] == nil {
+			continue
+		}
+		args = append(args, errs[i].Error())
+		if cause == nil {
+			cause = errs[i]
+		}
+	}
+	return &CheckError{Message: message, msg: p.localizer.sprintf(checkMessageKeys[message], args...), cause: cause}
+}
+
+func (p *checkService) SetLocale(locale Locale) ICheckService {
+	if locale != "" {
+		p.localizer.locale = locale
+	}
+	return p
+}
+
+func (p *checkService) SetMessages(messages Messages) ICheckService {
+	p.localizer.overrides = messages
+	return p
+}
+
 func (p *checkService) SetHeaderRule(headerRuleValidate *HeaderRuleValidate) ICheckService {
 	p.headerRuleValidate = headerRuleValidate
 	return p
 }
 
+func (p *checkService) SetSheet(selector SheetSelector) ICheckService {
+	if selector != nil {
+		p.selector = selector
+	}
+	return p
+}
+
+func (p *checkService) AddSheet(selector SheetSelector, skipRowNum int) ICheckService {
+	p.sheets = append(p.sheets, checkSheet{selector: selector, skipRowNum: skipRowNum})
+	return p
+}
+
+func (p *checkService) SetLogger(logger Logger, args ...interface{}) ICheckService {
+	if logger != nil {
+		p.logger = logger
+	}
+	p.logAttrs = args
+	return p
+}
+
+// 输出警告日志
+func (p *checkService) logWarn(msg string, err error) {
+	p.logger.Warn(msg, append([]interface{}{"phase", logPhaseCheck, "error", err}, p.logAttrs...)...)
+}
+
 func (p *checkService) Run() (totalRows int64, err error) {
 	p.tplFile, err = p.openTplFileFunc()
 	if err != nil {
@@ -70,7 +171,7 @@ func (p *checkService) Run() (totalRows int64, err error) {
 	}
 	defer func() {
 		if err1 := p.tplFile.Close(); err1 != nil {
-			fmt.Printf("close tpl file error: %+v \n", err1)
+			p.logWarn("close tpl file error", err1)
 		}
 	}()
 
@@ -80,72 +181,100 @@ func (p *checkService) Run() (totalRows int64, err error) {
 	}
 	defer func() {
 		if err1 := p.importFile.Close(); err1 != nil {
-			fmt.Printf("close import file error: %+v \n", err1)
+			p.logWarn("close import file error", err1)
 		}
 	}()
 	if p.maxRowNum <= 0 {
-		return 0, MaxRowNumError.Error()
+		return 0, p.newError(MaxRowNumError)
 	}
 
 	if p.importFile == nil || p.tplFile == nil {
-		return 0, TemplateError.Error()
+		return 0, p.newError(TemplateError)
+	}
+
+	// 主工作表和添加的工作表
+	sheets := append([]checkSheet{{
+		selector:           p.selector,
+		skipRowNum:         p.skipRowNum,
+		headerRuleValidate: p.headerRuleValidate,
+	}}, p.sheets...)
+
+	for _, sheet := range sheets {
+		var sheetRows int64
+		if sheetRows, err = p.checkSheet(sheet); err != nil {
+			return 0, err
+		}
+		totalRows += sheetRows
+	}
+
+	if totalRows <= 0 {
+		return 0, p.newError(EmptyFile)
+	}
+
+	// 总行数校验
+	if totalRows > p.maxRowNum {
+		return 0, p.newError(LargestRowNumberError)
 	}
 
-	p.tplFileRows, err = p.tplFile.Rows(p.tplFile.GetSheetName(0))
+	return totalRows, nil
+}
+
+// 检查工作表的头部行，返回工作表的数据行数
+func (p *checkService) checkSheet(sheet checkSheet) (totalRows int64, err error) {
+	var tplSheetName, importSheetName string
+	// 模板文件和导入文件的工作表不存在时都返回 *SheetNotFoundError
+	if tplSheetName, err = sheet.selector(p.tplFile); err != nil {
+		return 0, err
+	}
+	if importSheetName, err = sheet.selector(p.importFile); err != nil {
+		return 0, err
+	}
+
+	var tplFileRows, importFileRows RowIterator
+	tplFileRows, err = p.tplFile.NewRowIterator(tplSheetName)
 	if err != nil {
-		return 0, unknownError.Sprintf(err)
+		return 0, p.newError(unknownError, err)
 	}
 	defer func() {
-		if err1 := p.tplFileRows.Close(); err1 != nil {
-			fmt.Printf("close tpl file rows error: %+v \n", err1)
+		if err1 := tplFileRows.Close(); err1 != nil {
+			p.logWarn("close tpl file rows error", err1)
 		}
 	}()
 
-	p.importFileRows, err = p.importFile.Rows(p.importFile.GetSheetName(0))
+	importFileRows, err = p.importFile.NewRowIterator(importSheetName)
 	if err != nil {
-		return 0, unknownError.Sprintf(err)
+		return 0, p.newError(unknownError, err)
 	}
 	defer func() {
-		if err1 := p.importFileRows.Close(); err1 != nil {
-			fmt.Printf("close import file rows error: %+v \n", err1)
+		if err1 := importFileRows.Close(); err1 != nil {
+			p.logWarn("close import file rows error", err1)
 		}
 	}()
 
 	// 头部校验，包含附加列的头部校验
-	if !p.compareHeader() {
-		return 0, TemplateError.Error()
+	if !compareHeader(tplFileRows, importFileRows, sheet) {
+		return 0, p.newError(TemplateError)
 	}
 
-	totalRows = p.getTotalRows()
-
-	if totalRows <= 0 {
-		return 0, EmptyFile.Error()
-	}
-
-	// 总行数校验
-	if totalRows > p.maxRowNum {
-		return 0, LargestRowNumberError.Error()
-	}
-
-	return totalRows, nil
+	return getTotalRows(importFileRows), nil
 }
 
-func (p *checkService) compareHeader() bool {
+func compareHeader(tplFileRows, importFileRows RowIterator, sheet checkSheet) bool {
 
 	// 初始化了头部规则校验器，只需要检查固定列是否和模板一致和检查附加列的符合是否规则，然后直接返回结果
-	if p.headerRuleValidate != nil {
-		p.importFileRows.Next()
-		importFileRow, _ := p.importFileRows.Columns()
-		p.tplFileRows.Next()
-		tplFileRow, _ := p.tplFileRows.Columns()
-		return p.headerRuleValidate.Validate(tplFileRow, importFileRow)
+	if sheet.headerRuleValidate != nil {
+		importFileRows.Next()
+		importFileRow, _ := importFileRows.Columns()
+		tplFileRows.Next()
+		tplFileRow, _ := tplFileRows.Columns()
+		return sheet.headerRuleValidate.Validate(tplFileRow, importFileRow)
 	}
 
-	for i := 0; i < p.skipRowNum; i++ {
-		p.importFileRows.Next()
-		importFileRow, _ := p.importFileRows.Columns()
-		p.tplFileRows.Next()
-		tplFileRow, _ := p.tplFileRows.Columns()
+	for i := 0; i < sheet.skipRowNum; i++ {
+		importFileRows.Next()
+		importFileRow, _ := importFileRows.Columns()
+		tplFileRows.Next()
+		tplFileRow, _ := tplFileRows.Columns()
 
 		// 头部校验
 		if !equalStringSlice(tplFileRow, importFileRow) {
@@ -156,9 +285,9 @@ func (p *checkService) compareHeader() bool {
 }
 
 // 获取总行数
-func (p *checkService) getTotalRows() (total int64) {
-	for p.importFileRows.Next() {
-		rowData, _ := p.importFileRows.Columns()
+func getTotalRows(importFileRows RowIterator) (total int64) {
+	for importFileRows.Next() {
+		rowData, _ := importFileRows.Columns()
 		if isEmpty(rowData) {
 			continue
 		}
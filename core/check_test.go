@@ -0,0 +1,93 @@
+package core
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+// 新建导入检查，模板和导入文件为 xlsx，不输出日志
+func newTestCheck(t *testing.T, tplRows, importRows [][]interface{}) ICheckService {
+	t.Helper()
+
+	return newTestFileCheck(newTestFile(t, tplRows), newTestFile(t, importRows))
+}
+
+// 使用已有的模板和导入文件新建导入检查
+func newTestFileCheck(tplFile, importFile *File) ICheckService {
+	return NewCheckService(func() (*File, error) {
+		return tplFile, nil
+	}, func() (*File, error) {
+		return importFile, nil
+	}, 1, 100).SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestCheckLocale(t *testing.T) {
+	tpl := [][]interface{}{{"A", "B"}}
+	rows := [][]interface{}{{"A", "C"}, {"1", "2"}}
+	tests := []struct {
+		name   string
+		locale Locale
+		want   string
+	}{
+		{"default", "", string(TemplateError)},
+		{"en-US", LocaleEnUS, string(TemplateError)},
+		{"zh-CN", LocaleZhCN, "导入文件与模板不一致"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTestCheck(t, tpl, rows).SetLocale(tt.locale).Run()
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("got error %v, want %q", err, tt.want)
+			}
+			// 错误类型与语言无关
+			var checkErr *CheckError
+			if !errors.As(err, &checkErr) || checkErr.Message != TemplateError {
+				t.Errorf("got error %#v, want CheckError with TemplateError", err)
+			}
+		})
+	}
+
+	if total, err := newTestCheck(t, tpl, [][]interface{}{{"A", "B"}, {"1", "2"}}).Run(); err != nil || total != 1 {
+		t.Errorf("got %d, %v, want 1 row", total, err)
+	}
+}
+
+func TestCheckSheetNotFound(t *testing.T) {
+	rows := [][]interface{}{{"A", "B"}, {"1", "2"}}
+	tests := []struct {
+		name        string
+		tplSheet    bool // 模板文件是否有明细工作表
+		importSheet bool // 导入文件是否有明细工作表
+		add         bool // 明细工作表通过 AddSheet 添加，否则为主工作表
+	}{
+		{"template", false, true, false},
+		{"import", true, false, false},
+		{"added template", false, true, true},
+		{"added import", true, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tplFile, importFile := newTestFile(t, rows), newTestFile(t, rows)
+			if tt.tplSheet {
+				writeTestSheet(t, tplFile, "明细", rows)
+			}
+			if tt.importSheet {
+				writeTestSheet(t, importFile, "明细", rows)
+			}
+			check := newTestFileCheck(tplFile, importFile)
+			if tt.add {
+				check.AddSheet(SheetByName("明细"), 1)
+			} else {
+				check.SetSheet(SheetByName("明细"))
+			}
+
+			// 模板文件和导入文件的工作表不存在时返回相同的错误
+			var notFound *SheetNotFoundError
+			if _, err := check.Run(); !errors.As(err, &notFound) || notFound.Sheet != `"明细"` {
+				t.Errorf("got error %v, want SheetNotFoundError", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/nuominmin/import-kit/model"
+)
+
+// CheckpointStore 检查点存储。
+// 设置后任务运行中会在进度更新时保存检查点，重新运行同一个任务时跳过检查点中已经完成的行，
+// 并把检查点中的错误写入错误文件，任务完成后删除检查点
+type CheckpointStore interface {
+	// Load 加载检查点，没有检查点时返回 nil。工作表的 Errors 为所有保存过的错误
+	Load() (*model.Checkpoint, error)
+	// Save 保存检查点。工作表的 Errors 只包含上次保存成功之后新增的错误，需要追加到已保存的错误中，其它字段直接覆盖
+	Save(checkpoint *model.Checkpoint) error
+	// Delete 删除检查点
+	Delete() error
+}
+
+// 工作表的检查点记录。读取行在主 goroutine，完成行在提交或收集的 goroutine，所以需要加锁
+type checkpointTracker struct {
+	mu        sync.Mutex
+	resumed   *model.SheetCheckpoint  // 加载的检查点，运行中不会改变
+	skipped   map[int]struct{}        // 加载的检查点中大于水位线但已经完成的行
+	formIndex int                     // 水位线
+	queue     []int                   // 本次运行已经读取的行索引，按读取顺序
+	head      int                     // 队列中第一个未完成的行
+	done      map[int]struct{}        // 已经完成但还没有越过水位线的行
+	pending   []model.CheckpointError // 已完成行中还没有保存的错误，已保存的错误只在检查点存储中
+}
+
+func newCheckpointTracker(checkpoint *model.SheetCheckpoint) *checkpointTracker {
+	t := &checkpointTracker{
+		resumed:   checkpoint,
+		skipped:   make(map[int]struct{}),
+		formIndex: -1,
+		done:      make(map[int]struct{}),
+	}
+	if checkpoint != nil {
+		t.formIndex = checkpoint.FormIndex
+		for _, index := range checkpoint.DoneIndexes {
+			t.skipped[index] = struct{}{}
+		}
+	}
+	return t
+}
+
+// 是否为检查点中已经完成的行
+func (t *checkpointTracker) isDone(rowIndex int) bool {
+	if t.resumed == nil {
+		return false
+	}
+	if rowIndex <= t.resumed.FormIndex {
+		return true
+	}
+	_, ok := t.skipped[rowIndex]
+	return ok
+}
+
+// 记录读取的行
+func (t *checkpointTracker) read(rowIndex int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.queue = append(t.queue, rowIndex)
+}
+
+// 记录完成的分组，并推进水位线
+func (t *checkpointTracker) finish(group *rows, errors []model.CheckpointError) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, row := range group.rows {
+		t.done[row.index] = struct{}{}
+	}
+	t.pending = append(t.pending, errors...)
+
+	for ; t.head < len(t.queue); t.head++ {
+		index := t.queue[t.head]
+		if _, ok := t.done[index]; !ok {
+			break
+		}
+		t.formIndex = index
+		delete(t.done, index)
+	}
+
+	// 回收队列中已经越过水位线的部分
+	if t.head > 1024 && t.head*2 > len(t.queue) {
+		t.queue = append(t.queue[:0], t.queue[t.head:]...)
+		t.head = 0
+	}
+}
+
+// 生成工作表的检查点，只包含还没有保存的错误。
+// 错误不会被修改，检查点与记录共用底层数组，保存成功后再通过 saved 移除
+func (t *checkpointTracker) checkpoint(sheetName string) model.SheetCheckpoint {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	checkpoint := model.SheetCheckpoint{
+		Sheet:     sheetName,
+		FormIndex: t.formIndex,
+		Errors:    t.pending[:len(t.pending):len(t.pending)],
+	}
+	for index := range t.done {
+		checkpoint.DoneIndexes = append(checkpoint.DoneIndexes, index)
+	}
+	for index := range t.skipped {
+		if index > t.formIndex {
+			checkpoint.DoneIndexes = append(checkpoint.DoneIndexes, index)
+		}
+	}
+	sort.Ints(checkpoint.DoneIndexes)
+	return checkpoint
+}
+
+// 检查点保存成功，移除已经保存的 n 个错误。保存期间新增的错误仍然等待下次保存
+func (t *checkpointTracker) saved(n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.pending = t.pending[n:]
+}
+
+// 加载检查点，没有设置检查点存储或者试运行时不记录。
+// 检查点中的工作表与任务中的工作表按顺序对应，工作表不一致时返回错误，避免重复提交
+func (p *taskScheduler) loadCheckpoint() (err error) {
+	p.resumed = nil
+	for _, sheet := range p.sheets {
+		sheet.checkpoint = nil
+	}
+	if p.checkpointStore == nil || p.dryRun {
+		return nil
+	}
+
+	if p.resumed, err = p.checkpointStore.Load(); err != nil {
+		return err
+	}
+	if p.resumed != nil {
+		if len(p.resumed.Sheets) > len(p.sheets) {
+			return fmt.Errorf("checkpoint has %d sheets, but task has %d sheets. ", len(p.resumed.Sheets), len(p.sheets))
+		}
+		p.logInfo(logPhaseCheckpoint, "resume from checkpoint", "done_count", p.resumed.DoneCount)
+	}
+
+	for i, sheet := range p.sheets {
+		var checkpoint *model.SheetCheckpoint
+		if p.resumed != nil && i < len(p.resumed.Sheets) {
+			checkpoint = &p.resumed.Sheets[i]
+			if checkpoint.Sheet != sheet.sheetName {
+				return fmt.Errorf("checkpoint sheet %q does not match sheet %q. ", checkpoint.Sheet, sheet.sheetName)
+			}
+		}
+		sheet.checkpoint = newCheckpointTracker(checkpoint)
+	}
+	return nil
+}
+
+// 是否开启检查点
+func (p *taskScheduler) isEnableCheckpoint() bool {
+	return len(p.sheets) > 0 && p.sheets[0].checkpoint != nil
+}
+
+// 是否为检查点中已经完成的行，已经完成的行不会再次读取和提交
+func (s *sheetScheduler) isCheckpointDone(rowIndex int) bool {
+	return s.checkpoint != nil && s.checkpoint.isDone(rowIndex)
+}
+
+// 从检查点恢复完成行数和错误
+func (p *taskScheduler) restoreCheckpoint() {
+	if p.resumed == nil {
+		return
+	}
+
+	p.doneCount = p.resumed.DoneCount
+	p.progressDoneCount = p.doneCount
+	for _, sheet := range p.sheets {
+		if sheet.checkpoint == nil || sheet.checkpoint.resumed == nil {
+			continue
+		}
+		for _, e := range sheet.checkpoint.resumed.Errors {
+			var err error
+			if e.Message != "" {
+				err = errors.New(e.Message)
+			}
+			items := make([]ErrorReportItem, 0, len(e.Items))
+			for _, item := range e.Items {
+				items = append(items, ErrorReportItem{Code: item.Code, Field: item.Field, Header: item.Header, Cell: item.Cell, Message: item.Message, Params: item.Params})
+			}
+			sheet.errMessages.Append(e.RowIndex, e.Cells, err, e.Columns, items)
+		}
+	}
+}
+
+// 保存检查点，保存失败不影响任务运行
+func (p *taskScheduler) saveCheckpoint() {
+	if !p.isEnableCheckpoint() {
+		return
+	}
+
+	checkpoint := &model.Checkpoint{DoneCount: p.doneCount}
+	for _, sheet := range p.sheets {
+		checkpoint.Sheets = append(checkpoint.Sheets, sheet.checkpoint.checkpoint(sheet.sheetName))
+	}
+	// 保存失败时错误仍然等待下次保存
+	if err := p.checkpointStore.Save(checkpoint); err != nil {
+		p.logWarn(logPhaseCheckpoint, "save checkpoint error", "error", err)
+		return
+	}
+	for i, sheet := range p.sheets {
+		sheet.checkpoint.saved(len(checkpoint.Sheets[i].Errors))
+	}
+}
+
+// 删除检查点，任务完成后检查点不再需要
+func (p *taskScheduler) deleteCheckpoint() {
+	if !p.isEnableCheckpoint() {
+		return
+	}
+
+	if err := p.checkpointStore.Delete(); err != nil {
+		p.logWarn(logPhaseCheckpoint, "delete checkpoint error", "error", err)
+	}
+}
+
+// 检查点中的错误行
+func newCheckpointError(rowIndex int, cells []string, err error, cellMessages map[int]string, items []ErrorReportItem) model.CheckpointError {
+	e := model.CheckpointError{
+		RowIndex: rowIndex,
+		Cells:    cells,
+		Columns:  cellMessages,
+	}
+	if err != nil {
+		e.Message = err.Error()
+	}
+	for _, item := range items {
+		e.Items = append(e.Items, model.CheckpointErrorItem{Code: item.Code, Field: item.Field, Header: item.Header, Cell: item.Cell, Message: item.Message, Params: item.Params})
+	}
+	return e
+}
@@ -0,0 +1,165 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/nuominmin/import-kit/model"
+)
+
+// 测试用的检查点存储，保存时追加工作表的错误
+type testCheckpointStore struct {
+	mu         sync.Mutex
+	checkpoint *model.Checkpoint
+	failSaves  int // 前 failSaves 次保存返回错误
+	deleted    bool
+}
+
+func (p *testCheckpointStore) Load() (*model.Checkpoint, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.checkpoint, nil
+}
+
+func (p *testCheckpointStore) Save(checkpoint *model.Checkpoint) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.failSaves > 0 {
+		p.failSaves--
+		return errors.New("save checkpoint error")
+	}
+
+	saved := &model.Checkpoint{DoneCount: checkpoint.DoneCount}
+	for i, sheet := range checkpoint.Sheets {
+		var errs []model.CheckpointError
+		if p.checkpoint != nil && i < len(p.checkpoint.Sheets) {
+			errs = append(errs, p.checkpoint.Sheets[i].Errors...)
+		}
+		sheet.Errors = append(errs, sheet.Errors...)
+		saved.Sheets = append(saved.Sheets, sheet)
+	}
+	p.checkpoint = saved
+	return nil
+}
+
+func (p *testCheckpointStore) Delete() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.checkpoint, p.deleted = nil, true
+	return nil
+}
+
+func TestCheckpointResume(t *testing.T) {
+	// 250 行数据，第 50 和 200 行的数量为负数，第一次运行提交到第 150 行时取消
+	const rowNum = 250
+	data := [][]interface{}{{"A", "B", "C"}}
+	for i := 1; i <= rowNum; i++ {
+		b := i
+		if i == 50 || i == 200 {
+			b = -i
+		}
+		data = append(data, []interface{}{fmt.Sprintf("k%d", i), b, "x"})
+	}
+
+	tests := []struct {
+		name        string
+		batchSize   int
+		concurrency int
+		failSaves   int
+	}{
+		{"row by row", 1, 1, 0},
+		{"batch and concurrency", 5, 4, 0},
+		{"save error keeps errors pending", 1, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &testCheckpointStore{failSaves: tt.failSaves}
+			run := func(ctx context.Context, cancel func(row IRow)) *testImplementor {
+				implementor := &testImplementor{file: newTestFile(t, data)}
+				implementor.check = func(row IRow) error {
+					if cancel != nil {
+						cancel(row)
+					}
+					if b := row.GetData().(*testRow).B; b < 0 {
+						return fmt.Errorf("negative %d", b)
+					}
+					return nil
+				}
+				task := newTestTask(implementor, 1)
+				task.SetBatchSize(tt.batchSize)
+				task.SetConcurrency(tt.concurrency)
+				task.SetCheckpointStore(store)
+				task.SetContext(ctx)
+				err := task.Run()
+				if cancel != nil && !errors.Is(err, context.Canceled) {
+					t.Fatalf("got error %v, want context canceled", err)
+				}
+				if cancel == nil && err != nil {
+					t.Fatal(err)
+				}
+				return implementor
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			first := run(ctx, func(row IRow) {
+				if row.GetFormIndex() == 150 {
+					cancel()
+				}
+			})
+
+			// 取消时保存检查点，已保存的错误不会重复追加
+			if store.checkpoint == nil || store.deleted {
+				t.Fatal("checkpoint should be saved after cancel")
+			}
+			var savedErrors []int
+			for _, e := range store.checkpoint.Sheets[0].Errors {
+				savedErrors = append(savedErrors, e.RowIndex)
+			}
+			if !reflect.DeepEqual(savedErrors, []int{50}) {
+				t.Errorf("saved errors got %v, want [50]", savedErrors)
+			}
+			if store.checkpoint.DoneCount != first.doneCount {
+				t.Errorf("checkpoint done count got %d, want %d", store.checkpoint.DoneCount, first.doneCount)
+			}
+
+			second := run(context.Background(), nil)
+
+			// 两次运行提交的行不重复，并且覆盖所有行
+			submitted := append(first.submitted(), second.submitted()...)
+			sort.Ints(submitted)
+			for i, index := range submitted {
+				if index != i+1 {
+					t.Fatalf("submitted rows are duplicated or missing: %v", submitted)
+				}
+			}
+			if len(submitted) != rowNum {
+				t.Errorf("submitted %d rows, want %d", len(submitted), rowNum)
+			}
+
+			// 第二次运行的错误文件包含检查点中的错误
+			if second.doneCount != rowNum || second.errorCount != 2 {
+				t.Errorf("done count %d, error count %d, want %d and 2", second.doneCount, second.errorCount, rowNum)
+			}
+			want := [][]string{
+				{"A", "B", "C", "错误提示"},
+				{"k50", "-50", "x", "negative -50"},
+				{"k200", "-200", "x", "negative -200"},
+			}
+			if got := readTestFile(t, second.errs.GetErrFile(), "Sheet1"); !reflect.DeepEqual(got, want) {
+				t.Errorf("error file got %q, want %q", got, want)
+			}
+			if store.checkpoint != nil || !store.deleted {
+				t.Error("checkpoint should be deleted after the task is finished")
+			}
+		})
+	}
+}
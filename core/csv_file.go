@@ -0,0 +1,221 @@
+package core
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+)
+
+// csv/tsv 文件只有一个工作表，使用与 excelize 新建文件相同的名称
+const csvSheetName = "Sheet1"
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// CSVOptions csv/tsv 文件选项
+type CSVOptions struct {
+	Comma            rune     // 分隔符，默认为 ','
+	LazyQuotes       bool     // 是否允许不规范的引号
+	TrimLeadingSpace bool     // 是否忽略字段前的空白
+	WriteBOM         bool     // 生成 UTF-8 文件时是否写入 BOM，写入后 Excel 可以正确识别中文。导入文件带 BOM 时自动开启
+	Encoding         Encoding // 文件编码，默认自动识别。生成的错误文件使用相同的编码
+}
+
+// 分隔符
+func (p CSVOptions) comma() rune {
+	if p.Comma == 0 {
+		return ','
+	}
+	return p.Comma
+}
+
+// 文件格式
+func (p CSVOptions) format() FileFormat {
+	if p.comma() == '\t' {
+		return FileFormatTSV
+	}
+	return FileFormatCSV
+}
+
+// 分隔符
+func (e FileFormat) comma() rune {
+	if e == FileFormatTSV {
+		return '\t'
+	}
+	return ','
+}
+
+// csv/tsv 数据源
+type csvSource struct {
+	content []byte     // 文件内容，已解码为 UTF-8 并去除 BOM
+	records [][]string // 生成的文件行数据，只有错误文件会写入
+	options CSVOptions
+}
+
+// OpenBytesCSVFile 打开 csv 文件内容，options.Comma 为 '\t' 时即为 tsv 文件。
+// 内容会根据 options.Encoding 解码为 UTF-8，未指定编码时自动识别。内容带 UTF-8 BOM 时错误文件同样写入 BOM
+func OpenBytesCSVFile(content []byte, options CSVOptions) (*File, error) {
+	options.WriteBOM = options.WriteBOM || bytes.HasPrefix(content, utf8BOM)
+	content, encoding, err := decodeContent(content, options.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	options.Encoding = encoding
+	return openDecodedCSVFile(content, options), nil
+}
+
+// 打开已解码为 UTF-8 的 csv 文件内容
+func openDecodedCSVFile(content []byte, options CSVOptions) *File {
+	return &File{
+		format: options.format(),
+		csv: &csvSource{
+			content: content,
+			options: options,
+		},
+	}
+}
+
+func OpenBytesCSVFileFunc(content []byte, options CSVOptions) OpenFileFunc {
+	return func() (*File, error) {
+		return OpenBytesCSVFile(content, options)
+	}
+}
+
+// OpenBytesTSVFile 打开 tsv 文件内容
+func OpenBytesTSVFile(content []byte) (*File, error) {
+	return OpenBytesCSVFile(content, CSVOptions{Comma: '\t'})
+}
+
+func OpenBytesTSVFileFunc(content []byte) OpenFileFunc {
+	return func() (*File, error) {
+		return OpenBytesTSVFile(content)
+	}
+}
+
+func OpenLocalCSVFile(filename string, options CSVOptions) (*File, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return OpenBytesCSVFile(content, options)
+}
+
+func OpenLocalCSVFileFunc(filename string, options CSVOptions) OpenFileFunc {
+	return func() (*File, error) {
+		return OpenLocalCSVFile(filename, options)
+	}
+}
+
+// 新建一个 csv/tsv 文件，用于生成错误文件
+func newCSVFile(options CSVOptions) *File {
+	return &File{
+		format: options.format(),
+		csv:    &csvSource{options: options},
+	}
+}
+
+// DetectFileFormat 根据文件内容识别文件格式。
+// zip 或 ole 文件头识别为 xlsx，否则根据第一行中制表符和逗号的数量区分 tsv 和 csv
+func DetectFileFormat(content []byte) FileFormat {
+	if isWorkbook(content) {
+		return FileFormatXlsx
+	}
+	return detectTextFileFormat(content)
+}
+
+// 是否为 excel 工作簿，包含 zip 和加密后的 ole 文件
+func isWorkbook(content []byte) bool {
+	return bytes.HasPrefix(content, []byte("PK\x03\x04")) || bytes.HasPrefix(content, []byte{0xD0, 0xCF, 0x11, 0xE0})
+}
+
+// 根据第一行中制表符和逗号的数量区分 tsv 和 csv
+func detectTextFileFormat(content []byte) FileFormat {
+	firstLine := bytes.TrimPrefix(content, utf8BOM)
+	if i := bytes.IndexByte(firstLine, '\n'); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+	if bytes.Count(firstLine, []byte{'\t'}) > bytes.Count(firstLine, []byte{','}) {
+		return FileFormatTSV
+	}
+	return FileFormatCSV
+}
+
+// 行迭代器
+func (p *csvSource) rowIterator(sheet string) (RowIterator, error) {
+	if sheet != csvSheetName {
+		return nil, errors.New("sheet " + sheet + " does not exist. ")
+	}
+	reader := csv.NewReader(bytes.NewReader(p.content))
+	reader.Comma = p.options.comma()
+	reader.LazyQuotes = p.options.LazyQuotes
+	reader.TrimLeadingSpace = p.options.TrimLeadingSpace
+	reader.FieldsPerRecord = -1 // 每行的列数可以不同
+	return &csvRowIterator{reader: reader}, nil
+}
+
+// 写入 buffer。读取的文件直接返回原内容，生成的文件返回写入的行数据
+func (p *csvSource) writeToBuffer() (*bytes.Buffer, error) {
+	buffer := &bytes.Buffer{}
+	if p.records == nil {
+		buffer.Write(p.content)
+		return buffer, nil
+	}
+
+	writer := csv.NewWriter(buffer)
+	writer.Comma = p.options.comma()
+	if err := writer.WriteAll(p.records); err != nil {
+		return nil, err
+	}
+
+	if p.options.Encoding != EncodingAuto && p.options.Encoding != EncodingUTF8 {
+		content, err := encodeContent(buffer.Bytes(), p.options.Encoding)
+		if err != nil {
+			return nil, err
+		}
+		// UTF-16 文件需要 BOM，Excel 和 DetectEncoding 才能识别
+		switch p.options.Encoding {
+		case EncodingUTF16LE:
+			content = append(append([]byte{}, utf16LEBOM...), content...)
+		case EncodingUTF16BE:
+			content = append(append([]byte{}, utf16BEBOM...), content...)
+		}
+		return bytes.NewBuffer(content), nil
+	}
+
+	if p.options.WriteBOM {
+		return bytes.NewBuffer(append(append([]byte{}, utf8BOM...), buffer.Bytes()...)), nil
+	}
+	return buffer, nil
+}
+
+// csv/tsv 行迭代器
+type csvRowIterator struct {
+	reader *csv.Reader
+	record []string
+	err    error
+}
+
+func (p *csvRowIterator) Next() bool {
+	if p.err != nil {
+		return false
+	}
+	p.record, p.err = p.reader.Read()
+	if errors.Is(p.err, io.EOF) {
+		p.err = nil
+		return false
+	}
+	return p.err == nil
+}
+
+func (p *csvRowIterator) Columns() ([]string, error) {
+	return p.record, p.err
+}
+
+func (p *csvRowIterator) Error() error {
+	return p.err
+}
+
+func (p *csvRowIterator) Close() error {
+	return nil
+}
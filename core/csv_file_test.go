@@ -0,0 +1,196 @@
+package core
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDetectFileFormat(t *testing.T) {
+	xlsx, err := newFile().WriteToBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		content []byte
+		want    FileFormat
+	}{
+		{"xlsx", xlsx.Bytes(), FileFormatXlsx},
+		{"ole", []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1}, FileFormatXlsx},
+		{"csv", []byte("a,b,c\n1,2,3\n"), FileFormatCSV},
+		{"tsv", []byte("a\tb\tc\n1\t2\t3\n"), FileFormatTSV},
+		{"tsv with comma in cell", []byte("a,1\tb\tc\n"), FileFormatTSV},
+		{"csv with tab in cell", []byte("a\t1,b,c\n"), FileFormatCSV},
+		{"tsv with bom", []byte("\xEF\xBB\xBFa\tb\n"), FileFormatTSV},
+		{"only first line", []byte("a,b\nc\td\te\tf\n"), FileFormatCSV},
+		{"single column", []byte("a\n"), FileFormatCSV},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectFileFormat(tt.content); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenBytesAutoFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		format  FileFormat
+		want    [][]string
+	}{
+		{
+			name:    "csv",
+			content: "A,B,C\nk1,1,x\n",
+			format:  FileFormatCSV,
+			want:    [][]string{{"A", "B", "C"}, {"k1", "1", "x"}},
+		},
+		{
+			name:    "quoted comma, quote and line break",
+			content: "A,B\n\"x,1\",\"say \"\"hi\"\"\"\n\"line1\nline2\",y\n",
+			format:  FileFormatCSV,
+			want:    [][]string{{"A", "B"}, {"x,1", `say "hi"`}, {"line1\nline2", "y"}},
+		},
+		{
+			name:    "bom is removed",
+			content: "\xEF\xBB\xBFA,B\n1,2\n",
+			format:  FileFormatCSV,
+			want:    [][]string{{"A", "B"}, {"1", "2"}},
+		},
+		{
+			name:    "crlf and rows with different columns",
+			content: "A,B,C\r\n1\r\n1,2,3,4\r\n",
+			format:  FileFormatCSV,
+			want:    [][]string{{"A", "B", "C"}, {"1"}, {"1", "2", "3", "4"}},
+		},
+		{
+			name:    "tsv",
+			content: "A\tB\n\"x\ty\"\t1,2\n",
+			format:  FileFormatTSV,
+			want:    [][]string{{"A", "B"}, {"x\ty", "1,2"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := OpenBytesAutoFile([]byte(tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.Format() != tt.format {
+				t.Errorf("format got %s, want %s", f.Format(), tt.format)
+			}
+			if got := readTestRows(t, f); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVRowIteratorError(t *testing.T) {
+	tests := []struct {
+		name    string
+		options CSVOptions
+		wantErr bool
+	}{
+		{"strict quotes", CSVOptions{}, true},
+		{"lazy quotes", CSVOptions{LazyQuotes: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := OpenBytesCSVFile([]byte("A,B\na\"b,c\n"), tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			iterator, err := f.NewRowIterator(f.GetSheetName(0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for iterator.Next() {
+			}
+			if gotErr := iterator.Error() != nil; gotErr != tt.wantErr {
+				t.Errorf("got error %v, want error %v", iterator.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCSVErrorFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		options CSVOptions
+		format  ErrorFileFormat
+		want    FileFormat
+		bom     bool
+	}{
+		{"csv source", "A,B,C\na,b,c\nk1,1,\"x,1\"\nk2,-2,\"y,2\"\n", CSVOptions{}, ErrorFileFormatSource, FileFormatCSV, false},
+		{"csv source with bom", "A,B,C\na,b,c\nk1,1,\"x,1\"\nk2,-2,\"y,2\"\n", CSVOptions{WriteBOM: true}, ErrorFileFormatSource, FileFormatCSV, true},
+		{"csv source from bom input", "\xEF\xBB\xBFA,B,C\na,b,c\nk1,1,\"x,1\"\nk2,-2,\"y,2\"\n", CSVOptions{}, ErrorFileFormatSource, FileFormatCSV, true},
+		{"tsv source", "A\tB\tC\na\tb\tc\nk1\t1\tx,1\nk2\t-2\ty,2\n", CSVOptions{Comma: '\t'}, ErrorFileFormatSource, FileFormatTSV, false},
+		{"csv copy", "A,B,C\na,b,c\nk1,1,\"x,1\"\nk2,-2,\"y,2\"\n", CSVOptions{}, ErrorFileFormatCopy, FileFormatCSV, false},
+		{"csv xlsx", "A,B,C\na,b,c\nk1,1,\"x,1\"\nk2,-2,\"y,2\"\n", CSVOptions{}, ErrorFileFormatXlsx, FileFormatXlsx, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := OpenBytesCSVFile([]byte(tt.content), tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			implementor := &testImplementor{file: f}
+			task := newTestTask(implementor, 2)
+			task.SetErrorFileFormat(tt.format)
+			if err = task.Run(); err != nil {
+				t.Fatal(err)
+			}
+
+			errFile := implementor.errs.GetErrFile()
+			if errFile.Format() != tt.want {
+				t.Fatalf("error file format got %s, want %s", errFile.Format(), tt.want)
+			}
+			buf, err := errFile.WriteToBuffer()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hasBOM := bytes.HasPrefix(buf.Bytes(), utf8BOM); hasBOM != tt.bom {
+				t.Errorf("bom got %v, want %v", hasBOM, tt.bom)
+			}
+
+			// csv/tsv 的每行列数相同，xlsx 读取时去掉行尾的空单元格
+			want := [][]string{{"A", "B", "C", "错误提示"}, {"a", "b", "c", ""}, {"k2", "-2", "y,2", "negative -2"}}
+			if tt.want == FileFormatXlsx {
+				want[1] = want[1][:3]
+			}
+			if got := readTestFile(t, errFile, "Sheet1"); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// 通过行迭代器读取第一个工作表的所有行
+func readTestRows(t *testing.T, f *File) [][]string {
+	t.Helper()
+
+	iterator, err := f.NewRowIterator(f.GetSheetName(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iterator.Close()
+
+	var rows [][]string
+	for iterator.Next() {
+		columns, err := iterator.Columns()
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, columns)
+	}
+	if err = iterator.Error(); err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"strings"
+)
+
+// DetailImplementor 明细工作表的实现者。明细行不会单独提交，而是随关联的主表行一起提交，通过 IRow.GetDetails 获取
+type DetailImplementor interface {
+	// TransferStruct 对应明细工作表的中转结构体
+	TransferStruct() interface{}
+}
+
+// 明细工作表不会单独提交
+type detailImplementor struct {
+	DetailImplementor
+}
+
+func (detailImplementor) Submit(IRows) {}
+
+// 主表和明细工作表的关联原因
+const (
+	DetailReasonNoDetails  = "no_details" // 主表行没有对应的明细行
+	DetailReasonDuplicated = "duplicated" // 明细行已经关联到其它主表行，主表的关联列重复
+	DetailReasonInvalid    = "invalid"    // 明细行存在单元格解析或校验错误
+	DetailReasonOrphan     = "orphan"     // 明细行没有对应的主表行
+)
+
+// DetailError 主表和明细工作表的关联错误
+type DetailError struct {
+	Reason string // 原因，例如 DetailReasonOrphan
+	msg    string
+}
+
+func (p *DetailError) Error() string {
+	return p.msg
+}
+
+// 关联错误，消息的 key 为 "detail." + 关联原因
+func newDetailError(l localizer, reason string, args ...interface{}) *DetailError {
+	return &DetailError{Reason: reason, msg: l.sprintf("detail."+reason, args...)}
+}
+
+// 主表和明细工作表的关联
+type detailLink struct {
+	sheet        *sheetScheduler  // 明细工作表
+	masterColumn int              // 主表的关联列索引
+	detailColumn int              // 明细工作表的关联列索引
+	keys         []string         // 关联列的值，按第一次出现的顺序
+	rows         map[string]*rows // 还没有关联到主表行的明细行
+	linked       map[string]int   // 已经关联的关联列的值和主表行索引
+}
+
+// SetDetailSheet 设置明细工作表
+func (s *sheetScheduler) SetDetailSheet(selector SheetSelector, implementor DetailImplementor, skipRowNum int, masterColumn, detailColumn int) SheetScheduler {
+	detail := newSheetScheduler(s.task, selector, detailImplementor{implementor}, skipRowNum)
+	detail.master = s
+	s.detail = &detailLink{
+		sheet:        detail,
+		masterColumn: masterColumn,
+		detailColumn: detailColumn,
+	}
+	s.task.sheets = append(s.task.sheets, detail)
+	return detail
+}
+
+// 读取明细工作表，明细行按关联列的值暂存，等待主表行关联
+func (l *detailLink) read(f *File) error {
+	l.keys = nil
+	l.rows = make(map[string]*rows)
+	l.linked = make(map[string]int)
+	return l.sheet.readRows(f)
+}
+
+// 暂存明细行
+func (l *detailLink) append(iRowData interface{}, rowIndex int, cells []string, errs Errors) {
+	key := columnValue(cells, l.detailColumn)
+	details, ok := l.rows[key]
+	if !ok {
+		details = newRows()
+		l.rows[key] = details
+		l.keys = append(l.keys, key)
+	}
+	details.appendRow(iRowData, rowIndex, cells).SetErrs(errs...)
+}
+
+// 获取主表行的明细行，每组明细行只会关联到第一个主表行
+func (l *detailLink) link(rowIndex int, cells []string, localizer localizer) (*rows, error) {
+	key := columnValue(cells, l.masterColumn)
+	if linkedIndex, ok := l.linked[key]; ok {
+		return nil, newDetailError(localizer, DetailReasonDuplicated, linkedIndex+1)
+	}
+
+	details, ok := l.rows[key]
+	if !ok {
+		return nil, newDetailError(localizer, DetailReasonNoDetails)
+	}
+	delete(l.rows, key)
+	l.linked[key] = rowIndex
+
+	if details.IsErr() {
+		return details, newDetailError(localizer, DetailReasonInvalid)
+	}
+	return details, nil
+}
+
+// 没有关联到主表行的明细行直接写入错误文件
+func (l *detailLink) dispatchOrphans() {
+	for _, key := range l.keys {
+		details, ok := l.rows[key]
+		if !ok {
+			continue
+		}
+
+		details.SetRowsErrs(newDetailError(l.sheet.task.localizer, DetailReasonOrphan, key))
+		batch := newSubmitBatch(l.sheet)
+		batch.append(details)
+		batch.skipSubmit = true
+		l.sheet.task.submit(batch)
+	}
+	l.keys, l.rows = nil, nil
+}
+
+// 获取列的值，超过行数据的列为空
+func columnValue(cells []string, column int) string {
+	if column < 0 || column >= len(cells) {
+		return ""
+	}
+	return strings.TrimSpace(cells[column])
+}
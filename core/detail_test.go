@@ -0,0 +1,108 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestDetailSheet(t *testing.T) {
+	newFile := func(t *testing.T) *File {
+		f := newTestFile(t, [][]interface{}{
+			{"A", "B", "C"},
+			{"a", "b", "c"},
+			{"k1", 1, "x"},
+			{"k2", 2, "y"},
+			{"k3", 3, "z"},
+			{"k1", 4, "dup"},
+			{"k5", 5, "x"},
+		})
+		writeTestSheet(t, f, "明细", [][]interface{}{
+			{"主键", "数量"},
+			{"k1", 1},
+			{"k2", 20},
+			{"k1", 2},
+			{"k9", 1},
+			{"k5", "bad"},
+		})
+		return f
+	}
+
+	for _, concurrency := range []int{1, 3} {
+		var mu sync.Mutex
+		details := make(map[int][]int) // 主表行索引和明细行索引
+		implementor := &testImplementor{file: newFile(t)}
+		implementor.check = func(row IRow) error {
+			var indexes []int
+			row.GetDetails().Each(func(i int, detail IRow) bool {
+				indexes = append(indexes, detail.GetFormIndex())
+				if detail.GetData().(*testDetailRow).Qty > 10 {
+					detail.SetErrs(errors.New("数量过大"))
+				}
+				return false
+			})
+			mu.Lock()
+			details[row.GetFormIndex()] = indexes
+			mu.Unlock()
+			return nil
+		}
+		task := newTestTask(implementor, 2)
+		task.SetConcurrency(concurrency)
+		detail := task.SetDetailSheet(SheetByName("明细"), &testImplementor{transfer: func() interface{} { return &testDetailRow{} }}, 1, 0, 0)
+		// 明细行随主表行提交，明细工作表不能设置唯一列
+		if err := detail.SetUniqueColumn(0); err == nil {
+			t.Errorf("concurrency %d: detail sheet unique column should return an error", concurrency)
+		}
+		if err := task.Run(); err != nil {
+			t.Fatal(err)
+		}
+
+		// 只有关联成功的主表行提交，明细行随主表行一起提交
+		submitted := implementor.submitted()
+		sort.Ints(submitted)
+		if !reflect.DeepEqual(submitted, []int{2, 3}) {
+			t.Errorf("concurrency %d: submitted got %v, want [2 3]", concurrency, submitted)
+		}
+		if want := map[int][]int{2: {1, 3}, 3: {2}}; !reflect.DeepEqual(details, want) {
+			t.Errorf("concurrency %d: details got %v, want %v", concurrency, details, want)
+		}
+		if implementor.doneCount != 10 || implementor.errorCount != 6 {
+			t.Errorf("concurrency %d: done count %d, error count %d, want 10 and 6", concurrency, implementor.doneCount, implementor.errorCount)
+		}
+
+		errFile := implementor.errs.GetErrFile()
+		want := [][]string{
+			{"A", "B", "C", "错误提示"},
+			{"a", "b", "c"},
+			{"k3", "3", "z", "没有对应的明细行"},
+			{"k1", "4", "dup", "明细行已经关联到第 3 行"},
+			{"k5", "5", "x", "明细行存在错误"},
+		}
+		if got := readTestFile(t, errFile, "Sheet1"); !reflect.DeepEqual(got, want) {
+			t.Errorf("concurrency %d: got %q, want %q", concurrency, got, want)
+		}
+		want = [][]string{
+			{"主键", "数量", "错误提示"},
+			{"k2", "20", "数量过大"},
+			{"k9", "1", `没有对应的主表行 "k9"`},
+			{"k5", "bad", `数量(B6) "bad" 不是有效的整数`},
+		}
+		if got := readTestFile(t, errFile, "明细"); !reflect.DeepEqual(got, want) {
+			t.Errorf("concurrency %d: got %q, want %q", concurrency, got, want)
+		}
+
+		// 关联错误的错误码为关联原因，明细行存在错误与单元格解析失败的错误码相同
+		wantCounts := map[string]int{
+			DetailReasonNoDetails:  1,
+			DetailReasonDuplicated: 1,
+			DetailReasonInvalid:    2,
+			DetailReasonOrphan:     1,
+			ErrorCodeUnknown:       1,
+		}
+		if counts := implementor.errs.GetReport().Counts; !reflect.DeepEqual(counts, wantCounts) {
+			t.Errorf("concurrency %d: report counts got %v, want %v", concurrency, counts, wantCounts)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/nuominmin/import-kit/model"
+)
+
+// 支持试运行的实现者，记录校验的行索引和结束状态
+type dryRunImplementor struct {
+	*testImplementor
+
+	validated []int
+	status    TaskStatus
+}
+
+func (p *dryRunImplementor) Validate(ctx context.Context, rows IRows) {
+	rows.Each(func(i int, row IRow) bool {
+		p.validated = append(p.validated, row.GetFormIndex())
+		if err := p.checkRow(row); err != nil {
+			row.SetErrs(err)
+		}
+		return false
+	})
+}
+
+func (p *dryRunImplementor) EndWithStatus(errs IErrorMessages, doneCount int, errorCount int, status TaskStatus) error {
+	p.status = status
+	return p.End(errs, doneCount, errorCount)
+}
+
+func TestDryRun(t *testing.T) {
+	data := [][]interface{}{{"A", "B", "C"}, {"k1", 1, "x"}, {"k2", -2, "y"}, {"k3", "bad", "z"}}
+	tests := []struct {
+		name   string
+		dryRun bool
+		status TaskStatus
+	}{
+		{"dry run", true, TaskStatusDryRun},
+		{"run", false, TaskStatusFinished},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 已保存的检查点中第 1 行已经完成，试运行时不加载也不保存、删除
+			checkpoint := &model.Checkpoint{DoneCount: 1, Sheets: []model.SheetCheckpoint{{Sheet: "Sheet1", FormIndex: 1}}}
+			store := &testCheckpointStore{checkpoint: checkpoint}
+			implementor := &dryRunImplementor{testImplementor: &testImplementor{file: newTestFile(t, data)}}
+			task := newTestTask(implementor, 1)
+			task.SetDryRun(tt.dryRun)
+			task.SetCheckpointStore(store)
+			if err := task.Run(); err != nil {
+				t.Fatal(err)
+			}
+
+			if implementor.status != tt.status {
+				t.Errorf("status got %s, want %s", implementor.status, tt.status)
+			}
+			if tt.dryRun {
+				// 试运行时调用 Validate 代替 Submit，解析失败的行不校验
+				if got := implementor.submitted(); len(got) != 0 {
+					t.Errorf("submitted got %v, want none", got)
+				}
+				if want := []int{1, 2}; !reflect.DeepEqual(implementor.validated, want) {
+					t.Errorf("validated got %v, want %v", implementor.validated, want)
+				}
+				if store.checkpoint != checkpoint || store.deleted {
+					t.Errorf("checkpoint got %+v, deleted %v, want unchanged", store.checkpoint, store.deleted)
+				}
+				if implementor.doneCount != 3 || implementor.errorCount != 2 {
+					t.Errorf("done count %d, error count %d, want 3 and 2", implementor.doneCount, implementor.errorCount)
+				}
+			} else {
+				// 正常运行时从检查点继续，完成后删除检查点
+				if want := []int{2}; !reflect.DeepEqual(implementor.submitted(), want) {
+					t.Errorf("submitted got %v, want %v", implementor.submitted(), want)
+				}
+				if len(implementor.validated) != 0 {
+					t.Errorf("validated got %v, want none", implementor.validated)
+				}
+				if !store.deleted {
+					t.Error("checkpoint should be deleted")
+				}
+			}
+		})
+	}
+}
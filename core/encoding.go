@@ -0,0 +1,98 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Encoding 文本文件编码
+type Encoding string
+
+const (
+	EncodingAuto    Encoding = ""         // 自动识别：BOM -> UTF-8 -> GB18030
+	EncodingUTF8    Encoding = "utf-8"    // UTF-8
+	EncodingUTF16LE Encoding = "utf-16le" // UTF-16 小端
+	EncodingUTF16BE Encoding = "utf-16be" // UTF-16 大端
+	EncodingGBK     Encoding = "gbk"      // GBK，中文 Windows 下 Excel 另存为 csv 的默认编码
+	EncodingGB18030 Encoding = "gb18030"  // GB18030，兼容 GBK
+)
+
+var (
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+// DetectEncoding 识别文本内容的编码。
+// 优先根据 BOM 识别，其次校验是否为合法的 UTF-8，都不满足时认为是 GB18030
+func DetectEncoding(content []byte) Encoding {
+	switch {
+	case bytes.HasPrefix(content, utf8BOM):
+		return EncodingUTF8
+	case bytes.HasPrefix(content, utf16LEBOM):
+		return EncodingUTF16LE
+	case bytes.HasPrefix(content, utf16BEBOM):
+		return EncodingUTF16BE
+	case utf8.Valid(content):
+		return EncodingUTF8
+	default:
+		return EncodingGB18030
+	}
+}
+
+// 编解码器，UTF-8 返回 nil
+func (e Encoding) encoding() (encoding.Encoding, error) {
+	switch e {
+	case EncodingAuto, EncodingUTF8:
+		return nil, nil
+	case EncodingUTF16LE:
+		return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), nil
+	case EncodingUTF16BE:
+		return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), nil
+	case EncodingGBK:
+		return simplifiedchinese.GBK, nil
+	case EncodingGB18030:
+		return simplifiedchinese.GB18030, nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %s", e)
+	}
+}
+
+// 解码为 UTF-8 并去除 BOM，返回实际使用的编码
+func decodeContent(content []byte, e Encoding) ([]byte, Encoding, error) {
+	if e == EncodingAuto {
+		e = DetectEncoding(content)
+	}
+
+	enc, err := e.encoding()
+	if err != nil {
+		return nil, e, err
+	}
+
+	switch e {
+	case EncodingUTF16LE:
+		content = bytes.TrimPrefix(content, utf16LEBOM)
+	case EncodingUTF16BE:
+		content = bytes.TrimPrefix(content, utf16BEBOM)
+	}
+
+	if enc != nil {
+		if content, err = enc.NewDecoder().Bytes(content); err != nil {
+			return nil, e, fmt.Errorf("decode %s error: %s", e, err.Error())
+		}
+	}
+	return bytes.TrimPrefix(content, utf8BOM), e, nil
+}
+
+// 将 UTF-8 内容编码为指定编码
+func encodeContent(content []byte, e Encoding) ([]byte, error) {
+	enc, err := e.encoding()
+	if err != nil || enc == nil {
+		return content, err
+	}
+	return enc.NewEncoder().Bytes(content)
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// 编码测试内容，UTF-16 内容带 BOM
+func encodeTestContent(t *testing.T, content string, e Encoding) []byte {
+	t.Helper()
+
+	encoded, err := encodeContent([]byte(content), e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch e {
+	case EncodingUTF16LE:
+		return append(append([]byte{}, utf16LEBOM...), encoded...)
+	case EncodingUTF16BE:
+		return append(append([]byte{}, utf16BEBOM...), encoded...)
+	}
+	return encoded
+}
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		want    Encoding
+	}{
+		{"ascii", []byte("a,b\n"), EncodingUTF8},
+		{"utf-8", []byte("商品,数量\n"), EncodingUTF8},
+		{"utf-8 bom", append(append([]byte{}, utf8BOM...), "商品"...), EncodingUTF8},
+		{"utf-16le bom", encodeTestContent(t, "商品", EncodingUTF16LE), EncodingUTF16LE},
+		{"utf-16be bom", encodeTestContent(t, "商品", EncodingUTF16BE), EncodingUTF16BE},
+		{"gbk", encodeTestContent(t, "商品,数量\n", EncodingGBK), EncodingGB18030},
+		{"gb18030", encodeTestContent(t, "𠀀,数量\n", EncodingGB18030), EncodingGB18030},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectEncoding(tt.content); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenBytesEncodingFile(t *testing.T) {
+	want := [][]string{{"商品", "数量"}, {"苹果", "1"}}
+	tests := []struct {
+		name     string
+		content  []byte
+		encoding Encoding
+		format   FileFormat
+		want     Encoding
+		bom      bool
+	}{
+		{"auto utf-8", []byte("商品,数量\n苹果,1\n"), EncodingAuto, FileFormatCSV, EncodingUTF8, false},
+		{"auto utf-8 bom", []byte("\xEF\xBB\xBF商品,数量\n苹果,1\n"), EncodingAuto, FileFormatCSV, EncodingUTF8, true},
+		{"utf-8 bom", []byte("\xEF\xBB\xBF商品,数量\n苹果,1\n"), EncodingUTF8, FileFormatCSV, EncodingUTF8, true},
+		{"auto gbk", encodeTestContent(t, "商品,数量\n苹果,1\n", EncodingGBK), EncodingAuto, FileFormatCSV, EncodingGB18030, false},
+		{"auto utf-16le tsv", encodeTestContent(t, "商品\t数量\r\n苹果\t1\r\n", EncodingUTF16LE), EncodingAuto, FileFormatTSV, EncodingUTF16LE, false},
+		{"auto utf-16be", encodeTestContent(t, "商品,数量\n苹果,1\n", EncodingUTF16BE), EncodingAuto, FileFormatCSV, EncodingUTF16BE, false},
+		{"gbk", encodeTestContent(t, "商品,数量\n苹果,1\n", EncodingGBK), EncodingGBK, FileFormatCSV, EncodingGBK, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := OpenBytesEncodingFile(tt.content, tt.encoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.Format() != tt.format {
+				t.Errorf("format got %s, want %s", f.Format(), tt.format)
+			}
+			if f.csv.options.Encoding != tt.want {
+				t.Errorf("encoding got %s, want %s", f.csv.options.Encoding, tt.want)
+			}
+			if f.csv.options.WriteBOM != tt.bom {
+				t.Errorf("write bom got %v, want %v", f.csv.options.WriteBOM, tt.bom)
+			}
+			if got := readTestRows(t, f); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+
+	if _, err := OpenBytesEncodingFile([]byte("a,b\n"), "latin1"); err == nil {
+		t.Error("unsupported encoding should return an error")
+	}
+}
+
+func TestEncodedErrorFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding Encoding
+		content  string
+	}{
+		{"gbk", EncodingGBK, "商品,数量\n说明,说明\n苹果,1\n香蕉,-2\n"},
+		{"utf-16le", EncodingUTF16LE, "商品\t数量\r\n说明\t说明\r\n苹果\t1\r\n香蕉\t-2\r\n"},
+		{"utf-16be", EncodingUTF16BE, "商品,数量\n说明,说明\n苹果,1\n香蕉,-2\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := OpenBytesAutoFile(encodeTestContent(t, tt.content, tt.encoding))
+			if err != nil {
+				t.Fatal(err)
+			}
+			implementor := &testImplementor{file: f, transfer: func() interface{} {
+				return &struct {
+					Name string
+					Qty  int64
+				}{}
+			}, check: func(row IRow) error {
+				if row.GetFormIndex() == 3 {
+					return errors.New("数量不能为负数")
+				}
+				return nil
+			}}
+			task := newTestTask(implementor, 2)
+			task.SetErrorFileFormat(ErrorFileFormatSource)
+			if err = task.Run(); err != nil {
+				t.Fatal(err)
+			}
+
+			// 错误文件使用导入文件的编码，可以再次自动识别和解码
+			buf, err := implementor.errs.GetErrFile().WriteToBuffer()
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantEncoding := tt.encoding
+			if wantEncoding == EncodingGBK {
+				wantEncoding = EncodingGB18030
+			}
+			if got := DetectEncoding(buf.Bytes()); got != wantEncoding {
+				t.Errorf("error file encoding got %s, want %s", got, wantEncoding)
+			}
+			errFile, err := OpenBytesAutoFile(buf.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := [][]string{{"商品", "数量", "错误提示"}, {"说明", "说明", ""}, {"香蕉", "-2", "数量不能为负数"}}
+			if got := readTestRows(t, errFile); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
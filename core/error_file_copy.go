@@ -0,0 +1,406 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// 复制导入的工作簿或模板作为错误文件，失败时使用新的工作簿
+func (p *taskScheduler) copyErrorFileBase(f *File) {
+	base, isTemplate, err := p.openErrorFileBase(f)
+	if err != nil {
+		p.logWarn(logPhaseBuild, "copy error file workbook error, use a new workbook", "error", err)
+		return
+	}
+
+	// 模板中的工作表使用与导入文件相同的选择器选择
+	for _, sheet := range p.sheets {
+		sheet.errMessages.baseName = sheet.sheetName
+		if !isTemplate {
+			continue
+		}
+		if sheet.errMessages.baseName, err = sheet.selector(base); err != nil {
+			sheet.logWarn(logPhaseBuild, "select error file template sheet error, use a new workbook", "error", err)
+			_ = base.Close()
+			return
+		}
+	}
+	p.errMessages.base, p.errMessages.baseIsTemplate = base, isTemplate
+}
+
+// 打开错误文件的模板，没有设置模板时复制导入的工作簿
+func (p *taskScheduler) openErrorFileBase(f *File) (base *File, isTemplate bool, err error) {
+	if p.errorFileTpl != nil {
+		if base, err = p.errorFileTpl(); err != nil {
+			return nil, true, err
+		}
+		if base.isCSV() {
+			_ = base.Close()
+			return nil, true, errors.New("error file template must be a xlsx file. ")
+		}
+		return base, true, nil
+	}
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, false, err
+	}
+	base, err = OpenBytesFile(buf.Bytes())
+	return base, false, err
+}
+
+// 复制工作簿生成错误文件的写入器。
+// 流式写入会保留工作表的冻结窗格、数据验证和条件格式，只替换行数据，列宽和头部行的合并单元格在写入前重新设置；
+// 错误行的位置会改变，头部行之后的合并单元格、批注和超链接会被删除，复制导入文件时覆盖所有数据行的数据验证保留，其它只保留头部行的部分；
+// 头部行和错误行从复制的工作簿中读取单元格样式，复制导入文件时同时读取原始的单元格值，保留数字和布尔值
+type copyErrFileWriter struct {
+	*xlsxErrFileWriter
+	base          *File          // 复制的工作簿，即错误文件
+	sheetName     string         // 复制的工作簿中的工作表
+	copyValues    bool           // 是否读取原始的单元格值，复制模板时模板中没有数据行
+	styleRowIndex int            // 复制模板时数据行使用模板第一个数据行的样式
+	skipRowNum    int            // 头部行数量
+	cellStyles    map[int]int    // 单元格样式和标记错误后的样式
+	rows          [][]copiedCell // 流式写入前读取的行，写入的行会覆盖原有的行
+}
+
+// 从复制的工作簿中读取的单元格
+type copiedCell struct {
+	value   interface{}
+	styleID int
+}
+
+func (p *errorMessages) newCopySheetWriter(sheet *sheetErrors) (writer errFileWriter, err error) {
+	if p.errFile == nil {
+		p.errFile = p.base
+		if err = p.newStyles(); err != nil {
+			return nil, err
+		}
+	}
+
+	w := &copyErrFileWriter{
+		base:          p.base,
+		sheetName:     sheet.baseName,
+		copyValues:    !p.baseIsTemplate,
+		styleRowIndex: -1,
+		skipRowNum:    sheet.skipRowNum,
+		cellStyles:    make(map[int]int),
+	}
+	if p.baseIsTemplate {
+		w.styleRowIndex = sheet.skipRowNum
+	}
+
+	// 流式写入不会保留合并单元格，头部行的合并单元格写入时重新合并。
+	// 合并单元格需要在读取单元格之前取消，否则合并区域中的单元格读取到的都是左上角的值
+	var headerMerges []excelize.MergeCell
+	if headerMerges, err = w.unmergeCells(); err != nil {
+		return nil, err
+	}
+
+	// 流式写入会替换工作表的行数据，需要先读取头部行和错误行的单元格
+	for i := 0; i < sheet.skipRowNum; i++ {
+		w.rows = append(w.rows, w.readRow(i, sheet.maxColumnNum))
+	}
+	for _, e := range sheet.errors {
+		sourceIndex := e.rowIndex
+		if w.styleRowIndex >= 0 {
+			sourceIndex = w.styleRowIndex
+		}
+		w.rows = append(w.rows, w.readRow(sourceIndex, sheet.maxColumnNum))
+	}
+
+	// 删除头部行之后附加在原有行上的内容
+	if err = w.removeComments(); err != nil {
+		return nil, err
+	}
+	if err = w.removeHyperlinks(); err != nil {
+		return nil, err
+	}
+	if w.copyValues {
+		if err = w.clipDataValidations(w.lastRow(sheet)); err != nil {
+			return nil, err
+		}
+	}
+
+	// 流式写入不会保留列宽，需要重新设置
+	widths := make([]float64, sheet.maxColumnNum)
+	for i := range widths {
+		var column string
+		if column, err = excelize.ColumnNumberToName(i + 1); err != nil {
+			return nil, err
+		}
+		if widths[i], err = p.base.GetColWidth(w.sheetName, column); err != nil {
+			return nil, fmt.Errorf("get col width error: %s", err.Error())
+		}
+	}
+
+	var streamWriter *excelize.StreamWriter
+	if streamWriter, err = p.errFile.NewStreamWriter(w.sheetName); err != nil {
+		return nil, fmt.Errorf("new stream write error: %s", err.Error())
+	}
+	for i, width := range widths {
+		if err = streamWriter.SetColWidth(i+1, i+1, width); err != nil {
+			return nil, fmt.Errorf("set col width error: %s", err.Error())
+		}
+	}
+	for _, mergeCell := range headerMerges {
+		if err = streamWriter.MergeCell(mergeCell.GetStartAxis(), mergeCell.GetEndAxis()); err != nil {
+			return nil, fmt.Errorf("merge cell error: %s", err.Error())
+		}
+	}
+	w.xlsxErrFileWriter = &xlsxErrFileWriter{streamWriter: streamWriter, errorMessages: p, sheetName: w.sheetName, errColumn: sheet.maxColumnNum}
+	return w, nil
+}
+
+// 取消所有合并单元格，返回头部行中的合并单元格，延伸到头部行之后的合并单元格不保留
+func (p *copyErrFileWriter) unmergeCells() ([]excelize.MergeCell, error) {
+	mergeCells, err := p.base.GetMergeCells(p.sheetName)
+	if err != nil {
+		return nil, fmt.Errorf("get merge cells error: %s", err.Error())
+	}
+
+	res := make([]excelize.MergeCell, 0, len(mergeCells))
+	for _, mergeCell := range mergeCells {
+		if _, row, err := excelize.CellNameToCoordinates(mergeCell.GetEndAxis()); err == nil && row <= p.skipRowNum {
+			res = append(res, mergeCell)
+		}
+		if err = p.base.UnmergeCell(p.sheetName, mergeCell.GetStartAxis(), mergeCell.GetEndAxis()); err != nil {
+			return nil, fmt.Errorf("unmerge cell error: %s", err.Error())
+		}
+	}
+	return res, nil
+}
+
+// 删除头部行之后的批注
+func (p *copyErrFileWriter) removeComments() error {
+	comments, err := p.base.GetComments(p.sheetName)
+	if err != nil {
+		return fmt.Errorf("get comments error: %s", err.Error())
+	}
+
+	for _, comment := range comments {
+		if _, row, err := excelize.CellNameToCoordinates(comment.Cell); err != nil || row <= p.skipRowNum {
+			continue
+		}
+		if err = p.base.DeleteComment(p.sheetName, comment.Cell); err != nil {
+			return fmt.Errorf("delete comment error: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// 删除头部行之后的超链接。
+// excelize 没有获取和删除超链接的方法，通过反射读取已加载工作表的超链接列表，一次过滤掉头部行之后的超链接
+func (p *copyErrFileWriter) removeHyperlinks() error {
+	// 读取工作表的维度，同时确保工作表已经加载
+	if _, err := p.base.GetSheetDimension(p.sheetName); err != nil {
+		return fmt.Errorf("get sheet dimension error: %s", err.Error())
+	}
+
+	var sheetPath string
+	sheetMap := reflect.ValueOf(p.base.File).Elem().FieldByName("sheetMap")
+	if sheetMap.Kind() == reflect.Map {
+		for iter := sheetMap.MapRange(); iter.Next(); {
+			if strings.EqualFold(iter.Key().String(), p.sheetName) {
+				sheetPath = iter.Value().String()
+				break
+			}
+		}
+	}
+	worksheet, ok := p.base.Sheet.Load(sheetPath)
+	if !ok {
+		return fmt.Errorf("remove hyperlinks error: sheet %q is not loaded. ", p.sheetName)
+	}
+	hyperlinks := reflect.ValueOf(worksheet).Elem().FieldByName("Hyperlinks")
+	if !hyperlinks.IsValid() || hyperlinks.Kind() != reflect.Ptr {
+		return errors.New("remove hyperlinks error: unsupported worksheet. ")
+	}
+	if hyperlinks.IsNil() {
+		return nil
+	}
+
+	links := hyperlinks.Elem().FieldByName("Hyperlink")
+	kept := reflect.MakeSlice(links.Type(), 0, links.Len())
+	for i := 0; i < links.Len(); i++ {
+		ref := strings.SplitN(links.Index(i).FieldByName("Ref").String(), ":", 2)[0]
+		if _, row, err := excelize.CellNameToCoordinates(ref); err == nil && row <= p.skipRowNum {
+			kept = reflect.Append(kept, links.Index(i))
+		}
+	}
+	if kept.Len() == 0 {
+		hyperlinks.Set(reflect.Zero(hyperlinks.Type())) // 没有超链接时不能保留空的 hyperlinks 元素
+		return nil
+	}
+	links.Set(kept)
+	return nil
+}
+
+// 数据验证只保留不依赖行位置的部分：覆盖所有数据行的区域（例如整列的下拉框）保留，
+// 只针对部分数据行或单元格的区域截取头部行的部分。复制模板时模板的数据验证用于数据行，不需要处理
+func (p *copyErrFileWriter) clipDataValidations(lastRow int) error {
+	validations, err := p.base.GetDataValidations(p.sheetName)
+	if err != nil {
+		return fmt.Errorf("get data validations error: %s", err.Error())
+	}
+	if len(validations) == 0 {
+		return nil
+	}
+
+	if err = p.base.DeleteDataValidation(p.sheetName); err != nil {
+		return fmt.Errorf("delete data validation error: %s", err.Error())
+	}
+	for _, validation := range validations {
+		var sqref []string
+		for _, ref := range strings.Fields(validation.Sqref) {
+			if ref = p.clipRef(ref, lastRow); ref != "" {
+				sqref = append(sqref, ref)
+			}
+		}
+		if len(sqref) == 0 {
+			continue
+		}
+		validation.Sqref = strings.Join(sqref, " ")
+		if err = p.base.AddDataValidation(p.sheetName, validation); err != nil {
+			return fmt.Errorf("add data validation error: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// 截取区域，整列或者从第一个数据行覆盖到最后一个数据行的区域不截取，其它区域截取头部行的部分。
+// 区域在头部行之后或者无法解析时返回空
+func (p *copyErrFileWriter) clipRef(ref string, lastRow int) string {
+	cells := strings.SplitN(ref, ":", 2)
+	if len(cells) == 2 && isColumnName(cells[0]) && isColumnName(cells[1]) { // 整列，例如 C:C
+		return ref
+	}
+
+	startColumn, startRow, err := excelize.CellNameToCoordinates(cells[0])
+	if err != nil || startRow > p.skipRowNum+1 {
+		return ""
+	}
+	if len(cells) == 1 {
+		if startRow > p.skipRowNum {
+			return ""
+		}
+		return ref
+	}
+
+	endColumn, endRow, err := excelize.CellNameToCoordinates(cells[1])
+	if err != nil {
+		return ""
+	}
+	if endRow >= lastRow && endRow > p.skipRowNum {
+		return ref
+	}
+	if startRow > p.skipRowNum {
+		return ""
+	}
+	start, _ := excelize.CoordinatesToCellName(startColumn, startRow)
+	end, _ := excelize.CoordinatesToCellName(endColumn, min(endRow, p.skipRowNum))
+	return start + ":" + end
+}
+
+// 是否为列名，例如 C
+func isColumnName(name string) bool {
+	_, err := excelize.ColumnNameToNumber(name)
+	return err == nil
+}
+
+// 工作表的最后一行，从 1 开始
+func (p *copyErrFileWriter) lastRow(sheet *sheetErrors) int {
+	lastRow := p.skipRowNum
+	if dimension, err := p.base.GetSheetDimension(p.sheetName); err == nil {
+		if refs := strings.Split(dimension, ":"); len(refs) == 2 {
+			if _, row, err := excelize.CellNameToCoordinates(refs[1]); err == nil {
+				lastRow = max(lastRow, row)
+			}
+		}
+	}
+	if len(sheet.errors) > 0 {
+		lastRow = max(lastRow, sheet.errors[len(sheet.errors)-1].rowIndex+1)
+	}
+	return lastRow
+}
+
+// 读取行的单元格
+func (p *copyErrFileWriter) readRow(rowIndex, columnNum int) []copiedCell {
+	row := make([]copiedCell, columnNum)
+	for column := 0; column < columnNum; column++ {
+		cell, err := excelize.CoordinatesToCellName(column+1, rowIndex+1)
+		if err != nil {
+			continue
+		}
+		row[column].styleID, _ = p.base.GetCellStyle(p.sheetName, cell)
+		if !p.copyValues && rowIndex >= p.skipRowNum {
+			continue
+		}
+		row[column].value = p.cellValue(cell)
+	}
+	return row
+}
+
+// 原始的单元格值，数字和布尔值保留类型。错误行的位置会改变，公式只保留计算结果
+func (p *copyErrFileWriter) cellValue(cell string) interface{} {
+	raw, err := p.base.GetCellValue(p.sheetName, cell, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil
+	}
+	cellType, _ := p.base.GetCellType(p.sheetName, cell)
+	switch cellType {
+	case excelize.CellTypeBool:
+		return raw == "1"
+	case excelize.CellTypeNumber, excelize.CellTypeUnset:
+		if number, err := strconv.ParseFloat(raw, 64); err == nil {
+			return number
+		}
+	}
+	return raw
+}
+
+// 标记错误后的单元格样式，保留原有样式的数字格式和字体等，只修改填充
+func (p *copyErrFileWriter) cellStyle(styleID int) int {
+	if cellStyleID, ok := p.cellStyles[styleID]; ok {
+		return cellStyleID
+	}
+
+	cellStyleID := p.errorMessages.cellStyleID
+	if style, err := p.base.GetStyle(styleID); err == nil && style != nil {
+		style.Fill = excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"FFC7CE"}}
+		if id, err := p.base.NewStyle(style); err == nil {
+			cellStyleID = id
+		}
+	}
+	p.cellStyles[styleID] = cellStyleID
+	return cellStyleID
+}
+
+func (p *copyErrFileWriter) SetRow(rowIndex int, rowData []string, cellMessages map[int]string) error {
+	if rowIndex >= len(p.rows) {
+		return p.xlsxErrFileWriter.SetRow(rowIndex, rowData, cellMessages)
+	}
+
+	if err := p.addComments(rowIndex, cellMessages); err != nil {
+		return err
+	}
+
+	// 错误消息和原始行号使用新的样式，数据列使用复制的单元格
+	res := p.errorMessages.assembleData(p.errColumn, nil, rowData...)
+	copied := p.rows[rowIndex]
+	for i := 0; i < len(copied) && i < len(rowData); i++ {
+		cell := excelize.Cell{StyleID: copied[i].styleID, Value: rowData[i]}
+		if copied[i].value != nil {
+			cell.Value = copied[i].value
+		}
+		if _, ok := cellMessages[i]; ok {
+			cell.StyleID = p.cellStyle(cell.StyleID)
+		}
+		res[i] = cell
+	}
+	return p.streamWriter.SetRow(fmt.Sprintf("A%d", rowIndex+1), res)
+}
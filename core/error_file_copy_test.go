@@ -0,0 +1,250 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// 保存错误文件后重新打开
+func reopenTestFile(t *testing.T, f *File) *File {
+	t.Helper()
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := OpenBytesFile(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reopened
+}
+
+func TestCopyErrorFile(t *testing.T) {
+	f := newTestFile(t, [][]interface{}{
+		{"A", "B", "C"},
+		{"a", "b", "c"},
+		{"k1", 1, "x"},
+		{"k2", -2, "y"},
+		{"k3", 3, "z"},
+		{"k4", -4, "w"},
+	})
+	headerStyle, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	numberStyle, err := f.NewStyle(&excelize.Style{NumFmt: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dv := excelize.NewDataValidation(true)
+	dv.Sqref = "C1:C100"
+	rowDV := excelize.NewDataValidation(true)
+	rowDV.Sqref = "A1:A4 B4"
+	for _, fn := range []func() error{
+		func() error { return f.SetCellStyle("Sheet1", "A1", "C1", headerStyle) },
+		func() error { return f.SetCellStyle("Sheet1", "B3", "B6", numberStyle) },
+		func() error { return f.SetColWidth("Sheet1", "A", "A", 30) },
+		func() error {
+			return f.SetPanes("Sheet1", &excelize.Panes{Freeze: true, YSplit: 2, TopLeftCell: "A3", ActivePane: "bottomLeft"})
+		},
+		func() error { return f.MergeCell("Sheet1", "B1", "C1") },
+		func() error { return f.MergeCell("Sheet1", "A3", "A4") },
+		func() error {
+			return f.AddComment("Sheet1", excelize.Comment{Cell: "A1", Author: "x", Text: "表头说明"})
+		},
+		func() error {
+			return f.AddComment("Sheet1", excelize.Comment{Cell: "C4", Author: "x", Text: "数据批注"})
+		},
+		func() error { return f.SetCellHyperLink("Sheet1", "A2", "https://example.com/a", "External") },
+		func() error { return f.SetCellHyperLink("Sheet1", "C4", "https://example.com/c", "External") },
+		func() error { return dv.SetDropList([]string{"c", "x", "y", "z", "w"}) },
+		func() error { return f.AddDataValidation("Sheet1", dv) },
+		func() error {
+			return rowDV.SetRange(0, 10, excelize.DataValidationTypeWhole, excelize.DataValidationOperatorBetween)
+		},
+		func() error { return f.AddDataValidation("Sheet1", rowDV) },
+	} {
+		if err = fn(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTestSheet(t, f, "说明", [][]interface{}{{"导入说明"}})
+	if err = f.SetSheetVisible("说明", false); err != nil {
+		t.Fatal(err)
+	}
+
+	implementor := &testImplementor{file: f}
+	task := newTestTask(implementor, 2)
+	task.SetErrorFileFormat(ErrorFileFormatCopy)
+	if err = task.Run(); err != nil {
+		t.Fatal(err)
+	}
+	errFile := reopenTestFile(t, implementor.errs.GetErrFile())
+
+	// 错误行依次写入头部行之后，原有的其它行被删除，合并区域中的单元格保留原始值
+	rows, err := errFile.GetRows("Sheet1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"A", "B", "C", "错误提示"}, {"a", "b", "c"}, {"k2", "-2", "y", "negative -2"}, {"k4", "-4", "w", "negative -4"}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows got %q, want %q", rows, want)
+	}
+
+	// 保留工作簿的其它工作表、列宽、冻结窗格、头部行样式和数据行的数字格式
+	if visible, err := errFile.GetSheetVisible("说明"); err != nil || visible {
+		t.Errorf("hidden sheet got visible %v, error %v", visible, err)
+	}
+	if width, _ := errFile.GetColWidth("Sheet1", "A"); width != 30 {
+		t.Errorf("col width got %v, want 30", width)
+	}
+	if panes, _ := errFile.GetPanes("Sheet1"); !panes.Freeze || panes.YSplit != 2 {
+		t.Errorf("panes got %+v, want frozen 2 rows", panes)
+	}
+	if style := testCellStyle(t, errFile, "A1"); style.Font == nil || !style.Font.Bold {
+		t.Error("header style should be kept")
+	}
+	if style := testCellStyle(t, errFile, "B3"); style.NumFmt != 3 {
+		t.Errorf("number format got %d, want 3", style.NumFmt)
+	}
+	if value, _ := errFile.GetCellValue("Sheet1", "B3", excelize.Options{RawCellValue: true}); value != "-2" {
+		t.Errorf("number value got %q, want -2", value)
+	}
+
+	// 头部行之后的合并单元格、批注和超链接被删除，头部行的保留；
+	// 覆盖所有数据行的数据验证保留，只针对部分数据行的数据验证截取头部行的部分
+	mergeCells, err := errFile.GetMergeCells("Sheet1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mergeCells) != 1 || mergeCells[0].GetStartAxis() != "B1" || mergeCells[0].GetEndAxis() != "C1" {
+		t.Errorf("merge cells got %v, want B1:C1", mergeCells)
+	}
+	comments, err := errFile.GetComments("Sheet1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 1 || comments[0].Cell != "A1" {
+		t.Errorf("comments got %+v, want the comment on A1", comments)
+	}
+	if ok, _, _ := errFile.GetCellHyperLink("Sheet1", "A2"); !ok {
+		t.Error("header hyperlink should be kept")
+	}
+	if ok, _, _ := errFile.GetCellHyperLink("Sheet1", "C4"); ok {
+		t.Error("data hyperlink should be removed")
+	}
+	validations, err := errFile.GetDataValidations("Sheet1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqrefs := make([]string, 0, len(validations))
+	for _, validation := range validations {
+		sqrefs = append(sqrefs, validation.Sqref)
+	}
+	if want := []string{"C1:C100", "A1:A2"}; !reflect.DeepEqual(sqrefs, want) {
+		t.Errorf("data validations got %q, want %q", sqrefs, want)
+	}
+}
+
+func TestCopyErrorFileTemplate(t *testing.T) {
+	tpl := newTestFile(t, [][]interface{}{{"A", "B", "C"}, {"a", "b", "c"}, {"示例", 1, "x"}})
+	numberStyle, err := tpl.NewStyle(&excelize.Style{NumFmt: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tpl.SetCellStyle("Sheet1", "B3", "B3", numberStyle); err != nil {
+		t.Fatal(err)
+	}
+	dv := excelize.NewDataValidation(true)
+	dv.Sqref = "C3:C100"
+	if err = dv.SetDropList([]string{"x", "y"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = tpl.AddDataValidation("Sheet1", dv); err != nil {
+		t.Fatal(err)
+	}
+	buf, err := tpl.WriteToBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	implementor := &testImplementor{file: newTestFile(t, [][]interface{}{{"A", "B", "C"}, {"a", "b", "c"}, {"k1", -1, "x"}, {"k2", 2, "y"}, {"k3", -3, "z"}})}
+	task := newTestTask(implementor, 2)
+	task.SetErrorFileFormat(ErrorFileFormatCopy)
+	task.SetErrorFileTemplate(OpenBytesFileFunc(buf.Bytes()))
+	if err = task.Run(); err != nil {
+		t.Fatal(err)
+	}
+	errFile := reopenTestFile(t, implementor.errs.GetErrFile())
+
+	rows, err := errFile.GetRows("Sheet1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"A", "B", "C", "错误提示"}, {"a", "b", "c"}, {"k1", "-1", "x", "negative -1"}, {"k3", "-3", "z", "negative -3"}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows got %q, want %q", rows, want)
+	}
+	// 错误行使用模板第一个数据行的样式，模板的数据验证用于数据行
+	if style := testCellStyle(t, errFile, "B4"); style.NumFmt != 3 {
+		t.Errorf("number format got %d, want 3", style.NumFmt)
+	}
+	validations, err := errFile.GetDataValidations("Sheet1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(validations) != 1 || validations[0].Sqref != "C3:C100" {
+		t.Errorf("data validations got %+v, want C3:C100", validations)
+	}
+}
+
+// 单元格的样式
+func testCellStyle(t *testing.T, f *File, cell string) *excelize.Style {
+	t.Helper()
+
+	styleID, err := f.GetCellStyle("Sheet1", cell)
+	if err != nil {
+		t.Fatal(err)
+	}
+	style, err := f.GetStyle(styleID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return style
+}
+
+func TestCopyErrorFileHyperlinks(t *testing.T) {
+	// 每个数据行都有超链接，超链接在一次遍历中删除，行数较多时也不会逐行删除
+	const rowNum = 2000
+	data := [][]interface{}{{"A", "B", "C"}, {"a", "b", "c"}}
+	for i := 1; i <= rowNum; i++ {
+		data = append(data, []interface{}{"k", -i, "x"})
+	}
+	f := newTestFile(t, data)
+	for row := 1; row <= rowNum+2; row++ {
+		cell, _ := excelize.CoordinatesToCellName(3, row)
+		if err := f.SetCellHyperLink("Sheet1", cell, "Sheet1!A1", "Location"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	implementor := &testImplementor{file: f}
+	task := newTestTask(implementor, 2)
+	task.SetErrorFileFormat(ErrorFileFormatCopy)
+	if err := task.Run(); err != nil {
+		t.Fatal(err)
+	}
+	errFile := reopenTestFile(t, implementor.errs.GetErrFile())
+	for _, cell := range []string{"C1", "C2", "C3", "C2002"} {
+		ok, _, err := errFile.GetCellHyperLink("Sheet1", cell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := cell == "C1" || cell == "C2"; ok != want {
+			t.Errorf("%s hyperlink got %v, want %v", cell, ok, want)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package core
 import (
 	"fmt"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/xuri/excelize/v2"
 )
@@ -14,135 +16,349 @@ type IErrorMessages interface {
 	// Count 错误数量
 	Count() int
 
-	// Append 追加错误
-	Append(rowIndex int, err error)
-
-	// Build 打包错误文件
-	Build(rows [][]string, maxColumnNum, skipRowNum int) (err error)
+	// GetReport 获取错误报告，与错误文件包含相同的错误行，可以通过 JSON、CSV 方法序列化
+	GetReport() *ErrorReport
 }
 
+// 错误消息，错误文件中每个工作表对应一个错误工作表
 type errorMessages struct {
-	errors     []*errorMessage
-	errFile    *File
-	errStyleID int
+	sheets      []*sheetErrors
+	errFile     *File
+	errStyleID  int
+	cellStyleID int        // 错误单元格的样式
+	format      FileFormat // 错误文件格式
+	csvOptions  CSVOptions // 错误文件为 csv/tsv 时的选项
+	rowNumber   bool       // 是否在错误消息之后写入原始行号
+	localizer   localizer  // 表头和批注作者的语言
+	report      *ErrorReport
+
+	base           *File // 复制的工作簿，设置后错误文件在该工作簿上生成，见 ErrorFileFormatCopy
+	baseIsTemplate bool  // 复制的工作簿是否为模板
+}
+
+// 工作表的错误消息
+type sheetErrors struct {
+	name         string     // 工作表名称
+	baseName     string     // 复制的工作簿中对应的工作表名称
+	headerRows   [][]string // 头部行数据
+	maxColumnNum int        // 最大列数，错误消息写入到最后一列之后
+	skipRowNum   int        // 头部行数量
+	errors       []*errorMessage
+	rows         map[int]*errorMessage // 行索引和错误，同一行的多个错误合并为一个
 }
+
+// 行的错误
 type errorMessage struct {
-	rowIndex int
-	err      error
+	rowIndex     int
+	cells        []string          // 原始单元格数据
+	errs         Errors            // 行的所有错误，写入错误文件时使用 "; " 连接
+	cellMessages map[int]string    // 单元格错误的列索引和错误消息
+	items        []ErrorReportItem // 错误报告中的错误
+}
+
+// 错误文件行写入器
+type errFileWriter interface {
+	// SetRow 写入行数据，rowIndex 从 0 开始，errColumn 列为错误消息，之后为原始行号。
+	// cellMessages 为单元格错误，xlsx 会标记对应的单元格并添加批注
+	SetRow(rowIndex int, rowData []string, cellMessages map[int]string) error
+	// Flush 结束写入
+	Flush() error
+}
+
+func newErrorMessages(format FileFormat, csvOptions CSVOptions, rowNumber bool, localizer localizer) *errorMessages {
+	return &errorMessages{
+		format:     format,
+		csvOptions: csvOptions,
+		rowNumber:  rowNumber,
+		localizer:  localizer,
+	}
 }
 
-func newErrorMessages() IErrorMessages {
-	return &errorMessages{}
+// 添加工作表
+func (p *errorMessages) addSheet(name string) *sheetErrors {
+	sheet := &sheetErrors{name: name, rows: make(map[int]*errorMessage)}
+	p.sheets = append(p.sheets, sheet)
+	return sheet
 }
 
-func (p *errorMessages) newErrFile() (streamWriter *excelize.StreamWriter, err error) {
+// 创建错误文件中的工作表，csv/tsv 只有一个工作表
+func (p *errorMessages) newSheetWriter(sheet *sheetErrors) (writer errFileWriter, err error) {
+	name := sheet.name
+	if p.format == FileFormatCSV || p.format == FileFormatTSV {
+		p.csvOptions.Comma = p.format.comma()
+		p.errFile = newCSVFile(p.csvOptions)
+		return &csvErrFileWriter{source: p.errFile.csv}, nil
+	}
+
 	if p.errFile == nil {
 		p.errFile = newFile()
+		if err = p.errFile.SetSheetName(p.errFile.GetSheetName(0), name); err != nil {
+			return nil, fmt.Errorf("set sheet name error: %s", err.Error())
+		}
+		if err = p.newStyles(); err != nil {
+			return nil, err
+		}
+	} else if _, err = p.errFile.NewSheet(name); err != nil {
+		return nil, fmt.Errorf("new sheet error: %s", err.Error())
 	}
 
-	if streamWriter, err = p.errFile.NewStreamWriter("Sheet1"); err != nil {
+	var streamWriter *excelize.StreamWriter
+	if streamWriter, err = p.errFile.NewStreamWriter(name); err != nil {
 		return nil, fmt.Errorf("new stream write error: %s", err.Error())
 	}
 
+	return &xlsxErrFileWriter{streamWriter: streamWriter, errorMessages: p, sheetName: name, errColumn: sheet.maxColumnNum}, nil
+}
+
+// 创建错误消息和错误单元格的样式
+func (p *errorMessages) newStyles() (err error) {
 	p.errStyleID, err = p.errFile.NewStyle(&excelize.Style{Font: &excelize.Font{Color: "FF0000"}})
 	if err != nil {
-		return nil, fmt.Errorf("NewStyle error: %s", err.Error())
+		return fmt.Errorf("NewStyle error: %s", err.Error())
 	}
 
-	return streamWriter, nil
+	p.cellStyleID, err = p.errFile.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Color: "9C0006"},
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"FFC7CE"}},
+	})
+	if err != nil {
+		return fmt.Errorf("NewStyle error: %s", err.Error())
+	}
+	return nil
 }
 
 func (p *errorMessages) GetErrFile() *File {
 	return p.errFile
 }
 
-func (p *errorMessages) Append(rowIndex int, err error) {
-	p.errors = append(p.errors, &errorMessage{
-		rowIndex: rowIndex,
-		err:      err,
-	})
+func (p *errorMessages) GetReport() *ErrorReport {
+	if p.report == nil {
+		p.report = p.newReport()
+	}
+	return p.report
 }
 
 func (p *errorMessages) Count() int {
-	return len(p.errors)
+	count := 0
+	for _, sheet := range p.sheets {
+		count += sheet.Count()
+	}
+	return count
 }
 
-// 组装数据
-func (p *errorMessages) assembleData(d ...string) []interface{} {
-	if len(d) == 0 {
-		return []interface{}{}
-	}
-	lastValue := len(d) - 1
+// 组装数据，单元格错误的列使用错误单元格的样式，errColumn 列使用错误消息的样式
+func (p *errorMessages) assembleData(errColumn int, cellMessages map[int]string, d ...string) []interface{} {
 	res := make([]interface{}, len(d))
-	for i := 0; i < lastValue; i++ {
+	for i := 0; i < len(d); i++ {
+		if i == errColumn {
+			res[i] = excelize.Cell{StyleID: p.errStyleID, Value: d[i]}
+			continue
+		}
+		if _, ok := cellMessages[i]; ok {
+			res[i] = excelize.Cell{StyleID: p.cellStyleID, Value: d[i]}
+			continue
+		}
 		res[i] = excelize.Cell{Value: d[i]}
 	}
-
-	// 最后一列的数据写入和样式写入
-	res[lastValue] = excelize.Cell{StyleID: p.errStyleID, Value: d[lastValue]}
 	return res
 }
 
-func (p *errorMessages) Build(rows [][]string, maxColumnNum, skipRowNum int) (err error) {
+// Build 打包错误文件和错误报告。存在错误时每个工作表都会写入头部行，便于修改后重新上传
+func (p *errorMessages) Build() (err error) {
+	for _, sheet := range p.sheets {
+		sheet.sort()
+	}
+	p.report = p.newReport()
 	if p.Count() == 0 {
 		return nil
 	}
 
+	for _, sheet := range p.sheets {
+		var writer errFileWriter
+		if p.base != nil {
+			writer, err = p.newCopySheetWriter(sheet)
+		} else {
+			writer, err = p.newSheetWriter(sheet)
+		}
+		if err != nil {
+			return fmt.Errorf("newSheetWriter error: %s", err.Error())
+		}
+		if err = sheet.build(writer, p.rowNumber, p.localizer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Append 追加错误，cells 为该行的原始单元格数据，cellMessages 为单元格错误的列索引和错误消息，items 为错误报告中的错误。
+// 同一行的多个错误合并为一个，错误文件中按行索引排序
+func (p *sheetErrors) Append(rowIndex int, cells []string, err error, cellMessages map[int]string, items []ErrorReportItem) {
+	e, ok := p.rows[rowIndex]
+	if !ok {
+		e = &errorMessage{rowIndex: rowIndex}
+		p.rows[rowIndex] = e
+		p.errors = append(p.errors, e)
+	}
+	if e.cells == nil {
+		e.cells = cells
+	}
+	e.errs.Append(err)
+	for column, message := range cellMessages {
+		e.appendCellMessage(column, message)
+	}
+	for _, item := range items {
+		e.items = appendReportItem(e.items, item)
+	}
+}
+
+// 追加单元格错误，同一列的多个错误使用 "; " 连接，相同的错误只保留一个
+func (p *errorMessage) appendCellMessage(column int, message string) {
+	if p.cellMessages == nil {
+		p.cellMessages = make(map[int]string)
+	}
+	current, ok := p.cellMessages[column]
+	if !ok {
+		p.cellMessages[column] = message
+		return
+	}
+	for _, m := range strings.Split(current, "; ") {
+		if m == message {
+			return
+		}
+	}
+	p.cellMessages[column] = current + "; " + message
+}
+
+// Count 错误行数
+func (p *sheetErrors) Count() int {
+	return len(p.errors)
+}
+
+// 设置头部行，读取结束后最大列数才能确定
+func (p *sheetErrors) setHeader(headerRows [][]string, maxColumnNum, skipRowNum int) {
+	p.headerRows = headerRows
+	p.maxColumnNum = maxColumnNum
+	p.skipRowNum = skipRowNum
+}
+
+// 按行索引排序，每行只有一个错误，并发收集时顺序也是确定的
+func (p *sheetErrors) sort() {
 	sort.Slice(p.errors, func(i, j int) bool {
 		return p.errors[i].rowIndex < p.errors[j].rowIndex
 	})
+}
+
+// 写入工作表的头部行和错误行，错误已经按行索引排序。错误行从头部行之后依次写入，
+// rowNumber 为 true 时在错误消息之后写入原始行号，便于对照导入文件
+func (p *sheetErrors) build(writer errFileWriter, rowNumber bool, l localizer) (err error) {
+	columnNum := p.maxColumnNum + 1
+	if rowNumber {
+		columnNum++
+	}
+
+	// 写入头部行
+	for i := 0; i < len(p.headerRows) && i < p.skipRowNum; i++ {
+		rowData := make([]string, columnNum)
+		copy(rowData, p.headerRows[i])
+		if i == 0 {
+			rowData[p.maxColumnNum] = l.message(MessageErrorColumn)
+			if rowNumber {
+				rowData[p.maxColumnNum+1] = l.message(MessageRowNumberColumn)
+			}
+		}
+		_ = writer.SetRow(i, rowData, nil)
+	}
 
 	// 写入错误消息到每一行
-	mapErrorRowIndex := make(map[int]int) // map[row index] error index
-	for i := 0; i < p.Count(); i++ {
-		//if p.errors[i].err == nil {
-		//	log.Error("[importkit] errorMessages error is nil. ")
-		//	continue
-		//}
+	for i, e := range p.errors {
+		rowData := make([]string, columnNum)
+		copy(rowData, e.cells)
+		if printErr := e.errs.PrintError(); printErr != nil {
+			rowData[p.maxColumnNum] = printErr.Error()
+		}
+		if rowNumber {
+			rowData[p.maxColumnNum+1] = strconv.Itoa(e.rowIndex + 1)
+		}
 
-		mapErrorRowIndex[p.errors[i].rowIndex] = i
+		// 超过最大列数的单元格错误不会被标记
+		cellMessages := make(map[int]string, len(e.cellMessages))
+		for column, message := range e.cellMessages {
+			if column < p.maxColumnNum {
+				cellMessages[column] = message
+			}
+		}
+
+		_ = writer.SetRow(i+p.skipRowNum, rowData, cellMessages)
 	}
 
-	var streamWriter *excelize.StreamWriter
-	streamWriter, err = p.newErrFile()
-	if err != nil {
-		return fmt.Errorf("newErrFile error: %s", err.Error())
+	if err = writer.Flush(); err != nil {
+		return fmt.Errorf("stream write flush error: %s", err.Error())
 	}
 
-	var ok bool
-	var errIndex int
+	p.errors, p.rows = nil, nil
 
-	for i := 0; i < len(rows); i++ {
-		rowData := make([]string, maxColumnNum+1)
-		copy(rowData, rows[i])
-		rows[i] = nil
+	return nil
+}
 
-		if i < skipRowNum {
-			if i == 0 {
-				rowData[maxColumnNum] = "错误提示"
-			}
-			_ = streamWriter.SetRow(fmt.Sprintf("A%d", i+1), p.assembleData(rowData...))
-			continue
-		}
+// xlsx 错误文件写入器
+type xlsxErrFileWriter struct {
+	streamWriter  *excelize.StreamWriter
+	errorMessages *errorMessages
+	sheetName     string
+	errColumn     int                // 错误消息的列索引
+	comments      []excelize.Comment // 单元格错误的批注，流式写入结束后添加
+}
 
-		errIndex, ok = mapErrorRowIndex[i]
-		if !ok {
-			continue
-		}
+func (p *xlsxErrFileWriter) SetRow(rowIndex int, rowData []string, cellMessages map[int]string) error {
+	if err := p.addComments(rowIndex, cellMessages); err != nil {
+		return err
+	}
+	return p.streamWriter.SetRow(fmt.Sprintf("A%d", rowIndex+1), p.errorMessages.assembleData(p.errColumn, cellMessages, rowData...))
+}
 
-		if p.errors[errIndex].err != nil {
-			rowData[maxColumnNum] = p.errors[errIndex].err.Error()
+// 记录单元格错误的批注
+func (p *xlsxErrFileWriter) addComments(rowIndex int, cellMessages map[int]string) error {
+	columns := make([]int, 0, len(cellMessages))
+	for column := range cellMessages {
+		columns = append(columns, column)
+	}
+	sort.Ints(columns)
+	for _, column := range columns {
+		cell, err := excelize.CoordinatesToCellName(column+1, rowIndex+1)
+		if err != nil {
+			return err
 		}
+		p.comments = append(p.comments, excelize.Comment{Cell: cell, Author: p.errorMessages.localizer.message(MessageCommentAuthor), Text: cellMessages[column]})
+	}
+	return nil
+}
 
-		_ = streamWriter.SetRow(fmt.Sprintf("A%d", errIndex+skipRowNum+1), p.assembleData(rowData...))
+func (p *xlsxErrFileWriter) Flush() (err error) {
+	if err = p.streamWriter.Flush(); err != nil {
+		return err
 	}
 
-	if err = streamWriter.Flush(); err != nil {
-		return fmt.Errorf("stream write flush error: %s", err.Error())
+	for _, comment := range p.comments {
+		if err = p.errorMessages.errFile.AddComment(p.sheetName, comment); err != nil {
+			return fmt.Errorf("add comment error: %s", err.Error())
+		}
 	}
+	return nil
+}
 
-	p.errors = nil
-	mapErrorRowIndex = nil
+// csv/tsv 错误文件写入器，行号只用于保持与 xlsx 相同的顺序，不会产生空行
+type csvErrFileWriter struct {
+	source *csvSource
+}
 
+func (p *csvErrFileWriter) SetRow(_ int, rowData []string, _ map[int]string) error {
+	p.source.records = append(p.source.records, rowData)
+	return nil
+}
+
+func (p *csvErrFileWriter) Flush() error {
+	if p.source.records == nil {
+		p.source.records = [][]string{}
+	}
 	return nil
 }
@@ -0,0 +1,146 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestErrorMessagesAppend(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    FileFormat
+		rowNumber bool
+		want      [][]string
+	}{
+		{
+			name:   "xlsx",
+			format: FileFormatXlsx,
+			want: [][]string{
+				{"A", "B", "C", "错误提示"},
+				{"a", "b", "c"},
+				{"k3", "x", "3", "重复"},
+				{"k5", "y", "5", "错误一; 错误二"},
+			},
+		},
+		{
+			name:      "xlsx with row number",
+			format:    FileFormatXlsx,
+			rowNumber: true,
+			want: [][]string{
+				{"A", "B", "C", "错误提示", "原始行号"},
+				{"a", "b", "c"},
+				{"k3", "x", "3", "重复", "4"},
+				{"k5", "y", "5", "错误一; 错误二", "6"},
+			},
+		},
+		{
+			name:      "csv with row number",
+			format:    FileFormatCSV,
+			rowNumber: true,
+			want: [][]string{
+				{"A", "B", "C", "错误提示", "原始行号"},
+				{"a", "b", "c", "", ""},
+				{"k3", "x", "3", "重复", "4"},
+				{"k5", "y", "5", "错误一; 错误二", "6"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errMessages := newErrorMessages(tt.format, CSVOptions{}, tt.rowNumber, localizer{locale: LocaleZhCN})
+			sheet := errMessages.addSheet("Sheet1")
+
+			// 同一行的多个错误合并，错误消息和单元格错误去重，按行索引排序
+			cells := []string{"k5", "y", "5"}
+			sheet.Append(5, cells, errors.New("错误一"), map[int]string{1: "批注一"}, []ErrorReportItem{{Code: "a", Message: "错误一"}})
+			sheet.Append(3, []string{"k3", "x", "3"}, errors.New("重复"), nil, []ErrorReportItem{{Code: "b", Message: "重复"}})
+			sheet.Append(5, nil, errors.New("错误二"), map[int]string{1: "批注二", 2: "批注三"}, []ErrorReportItem{{Code: "a", Message: "错误二"}})
+			sheet.Append(5, nil, errors.New("错误一"), map[int]string{1: "批注一"}, []ErrorReportItem{{Code: "a", Message: "错误一"}})
+			sheet.setHeader([][]string{{"A", "B", "C"}, {"a", "b", "c"}}, 3, 2)
+			if sheet.Count() != 2 || errMessages.Count() != 2 {
+				t.Errorf("count got %d, want 2", errMessages.Count())
+			}
+			if err := errMessages.Build(); err != nil {
+				t.Fatal(err)
+			}
+
+			errFile := errMessages.GetErrFile()
+			if got := readTestFile(t, errFile, "Sheet1"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if report := errMessages.GetReport(); report.Total != 2 || len(report.Items) != 3 {
+				t.Errorf("report got %+v, want 2 rows and 3 items", report)
+			}
+			if tt.format != FileFormatXlsx {
+				return
+			}
+
+			// 同一个单元格的多个错误合并为一个批注
+			comments, err := errFile.GetComments("Sheet1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]string, len(comments))
+			for _, comment := range comments {
+				got[comment.Cell] = comment.Text
+			}
+			if want := map[string]string{"B4": "批注一; 批注二", "C4": "批注三"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("comments got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestErrorFileRowNumber(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    [][]string
+	}{
+		{
+			name:    "xlsx",
+			content: "",
+			want:    [][]string{{"A", "B", "C", "错误提示", "原始行号"}, {"a", "b", "c"}, {"k2", "-2", "y", "negative -2", "4"}, {"k4", "-4", "w", "negative -4", "6"}},
+		},
+		{
+			name:    "csv",
+			content: "A,B,C\na,b,c\nk1,1,x\nk2,-2,y\nk3,3,z\nk4,-4,w\n",
+			want:    [][]string{{"A", "B", "C", "错误提示", "原始行号"}, {"a", "b", "c", "", ""}, {"k2", "-2", "y", "negative -2", "4"}, {"k4", "-4", "w", "negative -4", "6"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestFile(t, [][]interface{}{{"A", "B", "C"}, {"a", "b", "c"}, {"k1", 1, "x"}, {"k2", -2, "y"}, {"k3", 3, "z"}, {"k4", -4, "w"}})
+			if tt.content != "" {
+				var err error
+				if f, err = OpenBytesAutoFile([]byte(tt.content)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// 实现者多次设置同一行的错误，错误文件中只有一行
+			implementor := &testImplementor{file: f}
+			implementor.check = func(row IRow) error {
+				if b := row.GetData().(*testRow).B; b < 0 {
+					row.SetErrs(fmt.Errorf("negative %d", b))
+					return fmt.Errorf("negative %d", b)
+				}
+				return nil
+			}
+			task := newTestTask(implementor, 2)
+			task.SetErrorFileFormat(ErrorFileFormatSource)
+			task.SetErrorFileRowNumber(true)
+			if err := task.Run(); err != nil {
+				t.Fatal(err)
+			}
+			if implementor.errorCount != 2 {
+				t.Errorf("error count got %d, want 2", implementor.errorCount)
+			}
+			if got := readTestFile(t, implementor.errs.GetErrFile(), "Sheet1"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
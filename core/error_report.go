@@ -0,0 +1,163 @@
+package core
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 内置的错误码。校验失败的错误码为校验规则，例如 required、max；
+// 主表和明细工作表关联失败的错误码为关联原因，例如 DetailReasonOrphan
+const (
+	ErrorCodeUnknown = "unknown" // 没有错误码的错误，例如 errors.New
+	ErrorCodeInvalid = "invalid" // 单元格数据无法解析为字段类型
+)
+
+// RowError 结构化的行错误，实现者通过 IRow.SetErrs 设置，错误报告中按 Code 统计。
+// Message 为错误文件中的错误消息，Cell 不为空时错误文件中会标记对应的单元格
+type RowError struct {
+	Code    string            // 错误码，例如 "duplicated_phone"
+	Field   string            // 字段名称
+	Header  string            // 列表头
+	Cell    string            // 单元格坐标，例如 C15
+	Message string            // 错误消息
+	Params  map[string]string // 错误消息的参数，前端可以按错误码和参数展示自己的错误消息
+}
+
+func (p *RowError) Error() string {
+	return p.Message
+}
+
+// ErrorReport 错误报告，与错误文件包含相同的错误行，便于前端按错误码统计和展示失败原因
+type ErrorReport struct {
+	Total  int               `json:"total"`  // 错误行数，与 End 的 errorCount 一致
+	Counts map[string]int    `json:"counts"` // 错误码和错误数量
+	Items  []ErrorReportItem `json:"items"`  // 每个错误，按工作表和行排序
+}
+
+// ErrorReportItem 错误报告中的错误
+type ErrorReportItem struct {
+	Sheet   string            `json:"sheet"`            // 工作表名称
+	Row     int               `json:"row"`              // 导入文件中的行号，从 1 开始
+	Code    string            `json:"code"`             // 错误码
+	Field   string            `json:"field,omitempty"`  // 字段名称
+	Header  string            `json:"header,omitempty"` // 列表头
+	Cell    string            `json:"cell,omitempty"`   // 单元格坐标
+	Message string            `json:"message"`          // 错误消息
+	Params  map[string]string `json:"params,omitempty"` // 错误消息的参数
+}
+
+// JSON 错误报告的 JSON 格式
+func (p *ErrorReport) JSON() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+// CSV 错误报告的 CSV 格式，每个错误一行，参数使用 "key=value; key=value" 连接
+func (p *ErrorReport) CSV() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	writer := csv.NewWriter(buf)
+	records := [][]string{{"sheet", "row", "code", "field", "header", "cell", "message", "params"}}
+	for _, item := range p.Items {
+		records = append(records, []string{
+			item.Sheet,
+			strconv.Itoa(item.Row),
+			item.Code,
+			item.Field,
+			item.Header,
+			item.Cell,
+			item.Message,
+			joinParams(item.Params),
+		})
+	}
+	if err := writer.WriteAll(records); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 按 key 排序连接参数
+func joinParams(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+params[key])
+	}
+	return strings.Join(pairs, "; ")
+}
+
+// 错误报告中的错误，相同的错误只保留一个。工作表和行号在生成报告时填充
+func (p *Errors) reportItems() []ErrorReportItem {
+	var items []ErrorReportItem
+	for i := 0; i < len(*p); i++ {
+		items = appendReportItem(items, newReportItem((*p)[i]))
+	}
+	return items
+}
+
+// 追加错误报告中的错误，错误码和错误消息相同时只保留一个
+func appendReportItem(items []ErrorReportItem, item ErrorReportItem) []ErrorReportItem {
+	for _, v := range items {
+		if v.Code == item.Code && v.Message == item.Message {
+			return items
+		}
+	}
+	return append(items, item)
+}
+
+// 根据错误的类型获取错误码和单元格
+func newReportItem(err error) ErrorReportItem {
+	item := ErrorReportItem{Code: ErrorCodeUnknown, Message: err.Error()}
+
+	var rowErr *RowError
+	if errors.As(err, &rowErr) {
+		item.Code, item.Field, item.Header, item.Cell, item.Params = rowErr.Code, rowErr.Field, rowErr.Header, rowErr.Cell, rowErr.Params
+		if item.Code == "" {
+			item.Code = ErrorCodeUnknown
+		}
+		return item
+	}
+
+	var cellErr *CellError
+	if errors.As(err, &cellErr) {
+		item.Code, item.Field, item.Header, item.Cell = ErrorCodeInvalid, cellErr.Field, cellErr.Header, cellErr.Cell
+		var validateErr *ValidateError
+		if errors.As(cellErr.Err, &validateErr) {
+			item.Code = validateErr.Rule
+			if validateErr.Param != "" {
+				item.Params = map[string]string{"param": validateErr.Param}
+			}
+		}
+		return item
+	}
+
+	var detailErr *DetailError
+	if errors.As(err, &detailErr) {
+		item.Code = detailErr.Reason
+	}
+	return item
+}
+
+// 生成错误报告
+func (p *errorMessages) newReport() *ErrorReport {
+	report := &ErrorReport{Counts: make(map[string]int), Items: []ErrorReportItem{}}
+	for _, sheet := range p.sheets {
+		report.Total += sheet.Count()
+		for _, e := range sheet.errors {
+			for _, item := range e.items {
+				item.Sheet, item.Row = sheet.name, e.rowIndex+1
+				report.Counts[item.Code]++
+				report.Items = append(report.Items, item)
+			}
+		}
+	}
+	return report
+}
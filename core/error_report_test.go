@@ -0,0 +1,111 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestErrorReport(t *testing.T) {
+	type content struct {
+		Name  string `validate:"required"`
+		Qty   int64
+		Phone string
+	}
+
+	implementor := &testImplementor{
+		file: newTestFile(t, [][]interface{}{
+			{"名称", "数量", "电话"},
+			{"说明", "说明", "说明"},
+			{"", 1, "138"},
+			{"k2", "x", "139"},
+			{"k3", 3, "dup"},
+			{"k4", 4, "137"},
+		}),
+		transfer: func() interface{} { return &content{} },
+		check: func(row IRow) error {
+			if row.GetData().(*content).Phone != "dup" {
+				return nil
+			}
+			row.SetErrs(&RowError{Code: "duplicated_phone", Field: "Phone", Header: "电话", Cell: "C5", Message: "电话重复", Params: map[string]string{"phone": "dup"}})
+			return errors.New("库存不足")
+		},
+	}
+	if err := newTestTask(implementor, 2).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 错误行数与 End 的 errorCount 一致，同一行的多个错误按错误码分别统计
+	report := implementor.errs.GetReport()
+	want := &ErrorReport{
+		Total:  3,
+		Counts: map[string]int{"required": 1, ErrorCodeInvalid: 1, "duplicated_phone": 1, ErrorCodeUnknown: 1},
+		Items: []ErrorReportItem{
+			{Sheet: "Sheet1", Row: 3, Code: "required", Field: "Name", Header: "名称", Cell: "A3", Message: "名称(A3) 不能为空"},
+			{Sheet: "Sheet1", Row: 4, Code: ErrorCodeInvalid, Field: "Qty", Header: "数量", Cell: "B4", Message: `数量(B4) "x" 不是有效的整数`},
+			{Sheet: "Sheet1", Row: 5, Code: "duplicated_phone", Field: "Phone", Header: "电话", Cell: "C5", Message: "电话重复", Params: map[string]string{"phone": "dup"}},
+			{Sheet: "Sheet1", Row: 5, Code: ErrorCodeUnknown, Message: "库存不足"},
+		},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("report got %+v, want %+v", report, want)
+	}
+	if report.Total != implementor.errorCount {
+		t.Errorf("report total got %d, want error count %d", report.Total, implementor.errorCount)
+	}
+
+	// RowError 的单元格在错误文件中被标记
+	comments, err := implementor.errs.GetErrFile().GetComments("Sheet1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cells := make(map[string]string, len(comments))
+	for _, comment := range comments {
+		cells[comment.Cell] = comment.Text
+	}
+	if wantCells := map[string]string{"A3": "不能为空", "B4": "不是有效的整数", "C5": "电话重复"}; !reflect.DeepEqual(cells, wantCells) {
+		t.Errorf("comments got %v, want %v", cells, wantCells)
+	}
+
+	data, err := report.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded ErrorReport
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&decoded, want) {
+		t.Errorf("json got %s", data)
+	}
+
+	data, err = report.CSV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCSV := "sheet,row,code,field,header,cell,message,params\n" +
+		"Sheet1,3,required,Name,名称,A3,名称(A3) 不能为空,\n" +
+		"Sheet1,4,invalid,Qty,数量,B4,\"数量(B4) \"\"x\"\" 不是有效的整数\",\n" +
+		"Sheet1,5,duplicated_phone,Phone,电话,C5,电话重复,phone=dup\n" +
+		"Sheet1,5,unknown,,,,库存不足,\n"
+	if string(data) != wantCSV {
+		t.Errorf("csv got %s, want %s", data, wantCSV)
+	}
+}
+
+func TestErrorReportEmpty(t *testing.T) {
+	implementor := &testImplementor{file: newTestFile(t, [][]interface{}{{"A", "B", "C"}, {"a", "b", "c"}, {"k1", 1, "x"}})}
+	if err := newTestTask(implementor, 2).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有错误时仍然生成报告，items 序列化为空数组
+	data, err := implementor.errs.GetReport().JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"total":0,"counts":{},"items":[]}`; string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+}
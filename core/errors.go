@@ -2,7 +2,10 @@ package core
 
 import (
 	"errors"
+	"fmt"
 	"strings"
+
+	"github.com/xuri/excelize/v2"
 )
 
 type Errors []error
@@ -63,3 +66,66 @@ func (p *Errors) PrintError() error {
 	}
 	return errors.New(strings.TrimSpace(strings.Join(p.ToErrMessages(), "; ")))
 }
+
+// 单元格错误的列索引和错误消息，同一列的多个错误使用 "; " 连接
+func (p *Errors) cellMessages() map[int]string {
+	var messages map[int]string
+	for i := 0; i < len(*p); i++ {
+		column, cellMessage, ok := cellMessage((*p)[i])
+		if !ok {
+			continue
+		}
+		if messages == nil {
+			messages = make(map[int]string)
+		}
+		if message, ok := messages[column]; ok {
+			messages[column] = message + "; " + cellMessage
+			continue
+		}
+		messages[column] = cellMessage
+	}
+	return messages
+}
+
+// 单元格错误的列索引和错误消息，*RowError 根据单元格坐标获取列索引
+func cellMessage(err error) (column int, message string, ok bool) {
+	var rowErr *RowError
+	if errors.As(err, &rowErr) {
+		if rowErr.Cell == "" {
+			return 0, "", false
+		}
+		col, _, err := excelize.CellNameToCoordinates(rowErr.Cell)
+		if err != nil {
+			return 0, "", false
+		}
+		return col - 1, rowErr.Message, true
+	}
+
+	var cellErr *CellError
+	if !errors.As(err, &cellErr) || cellErr.Err == nil {
+		return 0, "", false
+	}
+	return cellErr.Column, cellErr.Err.Error(), true
+}
+
+// CellError 单元格错误，例如单元格数据无法解析为字段类型或者校验失败。
+// 错误文件中会标记 Column 对应的单元格并添加批注，实现者也可以通过 IRow.SetErrs 设置单元格错误
+type CellError struct {
+	Field  string // 字段名称
+	Header string // 列表头
+	Cell   string // 单元格坐标，例如 C15
+	Column int    // 列索引，从 0 开始
+	Value  string // 单元格数据
+	Err    error  // 原始错误
+}
+
+func (p *CellError) Error() string {
+	if p.Value == "" {
+		return fmt.Sprintf("%s(%s) %s", p.Header, p.Cell, p.Err.Error())
+	}
+	return fmt.Sprintf("%s(%s) \"%s\" %s", p.Header, p.Cell, p.Value, p.Err.Error())
+}
+
+func (p *CellError) Unwrap() error {
+	return p.Err
+}
@@ -0,0 +1,165 @@
+package core
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// 测试用的中转结构体
+type testRow struct {
+	A string
+	B int64
+	C string
+}
+
+// 测试用的实现者，记录每次提交的行索引和 End 的参数。
+// 默认中转结构体为 testRow，B 为负数时设置行错误
+type testImplementor struct {
+	file     *File
+	transfer func() interface{}   // 中转结构体，为空时使用 testRow
+	check    func(row IRow) error // 提交时校验每一行，为空时使用 B 为负数的校验
+
+	mu         sync.Mutex
+	submits    [][]int // 每次提交的行索引
+	ended      bool
+	errs       IErrorMessages
+	doneCount  int
+	errorCount int
+}
+
+func (p *testImplementor) Start() error {
+	return nil
+}
+
+func (p *testImplementor) End(errs IErrorMessages, doneCount int, errorCount int) error {
+	p.ended = true
+	p.errs, p.doneCount, p.errorCount = errs, doneCount, errorCount
+	return nil
+}
+
+func (p *testImplementor) Progress() (doneInterval int, fn func(total, doneCount int) error) {
+	return 100, nil
+}
+
+func (p *testImplementor) OpenFile() OpenFileFunc {
+	return func() (*File, error) {
+		return p.file, nil
+	}
+}
+
+func (p *testImplementor) TransferStruct() interface{} {
+	if p.transfer != nil {
+		return p.transfer()
+	}
+	return &testRow{}
+}
+
+func (p *testImplementor) Submit(rows IRows) {
+	var indexes []int
+	rows.Each(func(i int, row IRow) bool {
+		indexes = append(indexes, row.GetFormIndex())
+		if err := p.checkRow(row); err != nil {
+			row.SetErrs(err)
+		}
+		return false
+	})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.submits = append(p.submits, indexes)
+}
+
+func (p *testImplementor) checkRow(row IRow) error {
+	if p.check != nil {
+		return p.check(row)
+	}
+	if data, ok := row.GetData().(*testRow); ok && data.B < 0 {
+		return fmt.Errorf("negative %d", data.B)
+	}
+	return nil
+}
+
+// 提交的所有行索引，按提交顺序
+func (p *testImplementor) submitted() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var indexes []int
+	for _, submit := range p.submits {
+		indexes = append(indexes, submit...)
+	}
+	return indexes
+}
+
+// 新建导入任务，不输出日志
+func newTestTask(implementor ImplementorContainer, skipRowNum int) TaskScheduler {
+	task := NewImportService(implementor, skipRowNum)
+	task.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	return task
+}
+
+// 新建 xlsx 文件，rows 写入 Sheet1
+func newTestFile(t *testing.T, rows [][]interface{}) *File {
+	t.Helper()
+
+	f := newFile()
+	writeTestSheet(t, f, "Sheet1", rows)
+	return f
+}
+
+// 写入工作表的行，工作表不存在时新建
+func writeTestSheet(t *testing.T, f *File, sheet string, rows [][]interface{}) {
+	t.Helper()
+
+	if index, _ := f.GetSheetIndex(sheet); index < 0 {
+		if _, err := f.NewSheet(sheet); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow(sheet, cell, &rows[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// 读取错误文件工作表的所有行，xlsx 文件会先保存再重新打开，csv/tsv 文件读取生成的内容
+func readTestFile(t *testing.T, f *File, sheet string) [][]string {
+	t.Helper()
+
+	if f == nil {
+		t.Fatal("error file is nil")
+	}
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.isCSV() {
+		reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(buf.Bytes(), utf8BOM)))
+		reader.Comma = f.Format().comma()
+		reader.FieldsPerRecord = -1
+		records, err := reader.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return records
+	}
+
+	reopened, err := OpenBytesFile(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := reopened.GetRows(sheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
@@ -1,14 +1,18 @@
 package core
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
+	"runtime/debug"
 	"sort"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/nuominmin/import-kit/model"
+	"github.com/xuri/excelize/v2"
 )
 
 type TaskScheduler interface {
@@ -16,7 +20,45 @@ type TaskScheduler interface {
 	SetUniqueColumn(indexes ...int) error
 	// SetErrorWriteBackMode 设置错误会写模式
 	SetErrorWriteBackMode(mode ErrorWriteBackMode)
-	// Run 运行扫描任务。 这是一个阻塞的方法，将会扫描全表后才退出
+	// SetErrorFileFormat 设置错误文件格式
+	SetErrorFileFormat(format ErrorFileFormat)
+	// SetErrorFileTemplate 设置错误文件的模板，错误文件格式为 ErrorFileFormatCopy 时复制模板代替导入的工作簿。
+	// 模板中的工作表使用与导入文件相同的选择器选择，数据行使用模板第一个数据行的样式
+	SetErrorFileTemplate(open OpenFileFunc)
+	// SetErrorFileRowNumber 设置是否在错误文件的错误消息之后写入原始行号，便于对照导入文件。
+	// 错误行总是从头部行之后依次写入，同一行的多个错误合并为一个
+	SetErrorFileRowNumber(enable bool)
+	// SetSubmitInvalidRows 设置单元格解析或校验失败的行是否仍然提交，默认不提交，直接写入错误文件
+	SetSubmitInvalidRows(submit bool)
+	// SetLocale 设置错误消息和错误文件表头的语言，默认简体中文。其它语言可以通过 RegisterMessages 添加
+	SetLocale(locale Locale)
+	// SetMessages 覆盖该任务的消息，key 见 MessageErrorColumn 等常量，没有覆盖的消息使用 SetLocale 设置的语言
+	SetMessages(messages Messages)
+	// SetBatchSize 设置批量提交的行数，多个分组合并为一次 Submit，默认 1 即逐个分组提交。
+	// 同一个分组的行不会被拆分到不同的批次，所以一个批次的行数可能超过 n
+	SetBatchSize(n int)
+	// SetLogger 设置日志，默认为 slog.Default()，logger 为 nil 时使用默认日志。args 为每条日志附带的属性，例如任务id
+	SetLogger(logger Logger, args ...interface{})
+	// SetMetrics 设置指标统计
+	SetMetrics(metrics Metrics)
+	// SetConcurrency 设置并发提交的 goroutine 数量，默认 1 即同步提交。
+	// 开启后 Submit 会被并发调用，同一个分组的行总是在同一次 Submit 中，错误按读取顺序收集
+	SetConcurrency(n int)
+	// SetCheckpointStore 设置检查点存储，设置后任务中断重新运行时从检查点继续，不会重复提交已经完成的行
+	SetCheckpointStore(store CheckpointStore)
+	// SetDryRun 设置试运行。试运行时解析、校验行数据并调用 RowsValidator 的 Validate，但不调用 Submit，
+	// 错误文件和行数照常通过 End 返回，实现了 StatusEnder 时结束状态为 TaskStatusDryRun。试运行不会读写检查点
+	SetDryRun(dryRun bool)
+	// SetSheet 设置主工作表，默认为第一个工作表。例如 SheetByName("导入数据")、SheetActive()、SheetFirstVisible()
+	SetSheet(selector SheetSelector)
+	// SetDetailSheet 设置主工作表的明细工作表，见 SheetScheduler.SetDetailSheet
+	SetDetailSheet(selector SheetSelector, implementor DetailImplementor, skipRowNum int, masterColumn, detailColumn int) SheetScheduler
+	// AddSheet 添加工作表。主工作表为第一个工作表，由 ImplementorContainer 处理；
+	// 添加的工作表有自己的中转结构体、头部行数量和实现者，在同一个任务中按添加顺序依次读取，错误文件中每个工作表对应一个错误工作表
+	AddSheet(selector SheetSelector, implementor SheetImplementor, skipRowNum int) SheetScheduler
+	// SetContext 设置上下文。上下文被取消时停止读取和提交，已处理行的错误仍然会生成错误文件，并以取消状态结束任务
+	SetContext(ctx context.Context)
+	// Run 运行扫描任务。 这是一个阻塞的方法，将会扫描全表或者上下文被取消后才退出，取消时返回上下文的错误
 	Run() error
 }
 
@@ -36,59 +78,88 @@ type ImplementorContainer interface {
 	Submit(rows IRows)
 }
 
+// ContextSubmitter 需要上下文的实现者可以实现该接口，提交时调用 SubmitWithContext 代替 Submit，ctx 为 SetContext 设置的上下文
+type ContextSubmitter interface {
+	// SubmitWithContext 提交数据，上下文被取消时应尽快返回
+	SubmitWithContext(ctx context.Context, rows IRows)
+}
+
+// StatusEnder 需要任务结束状态的实现者可以实现该接口，结束时调用 EndWithStatus 代替 End
+type StatusEnder interface {
+	// EndWithStatus 结束，status 为任务结束状态
+	EndWithStatus(errs IErrorMessages, doneCount int, errorCount int, status TaskStatus) error
+}
+
+// Failer 实现者实现该接口时，任务开始后运行失败、发生 panic 或者 End 失败时调用 Failed，避免任务一直处于开始状态
+type Failer interface {
+	// Failed 任务失败，err 为失败原因
+	Failed(err error) error
+}
+
+// RowsValidator 试运行时的校验，ImplementorContainer 实现该接口时试运行会调用 Validate 代替 Submit
+type RowsValidator interface {
+	// Validate 校验数据，与 Submit 一样通过 IRow.SetErrs 设置错误，不应该写入数据
+	Validate(ctx context.Context, rows IRows)
+}
+
 // 导入任务服务的结构体
 type taskScheduler struct {
 	id              string
-	rows            [][]string
-	rowsCount       int                  // 总行数
-	skipRowNum      int                  // 跳过行数。头部行的数量
-	sheetName       string               // 工作表
-	transferStruct  *transferStruct      // 转换结构字段数量
-	maxColumnNum    int                  // 列最大列数，取决于最大的字段数量和行数据列数，主要用于写入错误列时追加最到最后一列； 只有两种情况会写入这个值： 1. 初始化时的 transferStruct.Num, 2. 附加列时的最大列
+	ctx             context.Context      // 上下文
+	logger          Logger               // 日志
+	logAttrs        []interface{}        // 每条日志附带的属性
+	metrics         Metrics              // 指标统计
 	implementor     ImplementorContainer // 实现类
-	groupRows       *groupRows
-	headerFirstData *headerFirstData
+	sheets          []*sheetScheduler    // 工作表，第一个为主工作表
+	errorFileFormat ErrorFileFormat      // 错误文件格式
+	errorFileTpl    OpenFileFunc         // 错误文件的模板
+	errorRowNumber  bool                 // 错误文件是否写入原始行号
+	submitInvalid   bool                 // 单元格解析或校验失败的行是否仍然提交
+	localizer       localizer            // 错误消息的语言和覆盖的消息
+	batchSize       int                  // 批量提交的行数
+	concurrency     int                  // 并发提交的 goroutine 数量
+	checkpointStore CheckpointStore      // 检查点存储
+	dryRun          bool                 // 是否试运行
+
+	// 运行时的状态
+	rowsCount         int                              // 所有工作表的总行数
+	pool              *submitPool                      // 并发提交的工作池
+	errMessages       *errorMessages                   // 错误消息
+	resumed           *model.Checkpoint                // 加载的检查点
+	doneCount         int                              // 完成行数
+	progressDoneCount int                              // 上一次进度更新时的完成行数
+	progressInterval  int                              // 进度更新的完成间隔
+	progressFn        func(total, doneCount int) error // 进度更新
+	startTime         time.Time                        // 任务开始时间
+	ended             bool                             // 是否已经成功结束
 }
 
 func NewImportService(implementor ImplementorContainer, skipRowNum int) TaskScheduler {
-	if skipRowNum <= 0 {
-		skipRowNum = 2
-	}
 	id := strings.ReplaceAll(uuid.New().String(), "-", "")
 	svc := &taskScheduler{
-		id:             id,
-		skipRowNum:     skipRowNum,
-		sheetName:      "",
-		transferStruct: &transferStruct{},
-		maxColumnNum:   0,
-		implementor:    implementor,
-		groupRows: &groupRows{
-			rows:               []*uniqueColumn{},
-			keyMapIndex:        make(map[string]int),
-			indexes:            []int{},
-			indexesLen:         0,
-			errorWriteBackMode: ErrorWriteBackModeAnyRow,
-		},
-		headerFirstData: &headerFirstData{},
+		id:              id,
+		ctx:             context.Background(),
+		logger:          defaultLogger(),
+		metrics:         nopMetrics{},
+		implementor:     implementor,
+		errorFileFormat: ErrorFileFormatXlsx,
+		localizer:       localizer{locale: LocaleZhCN},
+		batchSize:       1,
+		concurrency:     1,
 	}
+	svc.sheets = []*sheetScheduler{newSheetScheduler(svc, SheetByIndex(0), implementor, skipRowNum)}
 	return svc
 }
 
-type transferStruct struct {
-	fieldNum int // 字段数量
-	typeOf   reflect.Type
-}
-
 type groupRows struct {
-	rows               []*uniqueColumn    // 唯一列分组行
-	keyMapIndex        map[string]int     // map[key]group index, key: row data value
-	indexes            []int              // 唯一列索引
-	indexesLen         int                // 唯一列索引长度
-	errorWriteBackMode ErrorWriteBackMode // 错误写回模式
+	keyMapRows         map[string]*uniqueColumn // map[key]唯一列分组行, key: 唯一列的行数据
+	indexes            []int                    // 唯一列索引
+	indexesLen         int                      // 唯一列索引长度
+	errorWriteBackMode ErrorWriteBackMode       // 错误写回模式
 }
 
 type uniqueColumn struct {
-	rowsData IRows // 行数据
+	rowsData *rows // 行数据
 	mergeNum int   // 合并数量
 }
 
@@ -98,273 +169,462 @@ type headerFirstData struct {
 	data []string // 数据
 }
 
-// 输出错误。输出的id主要用于搜索错误日志的时候可以通过标识过滤出同一个任务错误
-func (p *taskScheduler) outputError(format string, a ...interface{}) {
-	fmt.Printf("id: %s, error: %s", p.id, fmt.Sprintf(format, a...))
-}
-
-func (p *taskScheduler) Run() error {
+func (p *taskScheduler) Run() (err error) {
 	if p.implementor == nil {
 		return errors.New("implementor is empty. ")
 	}
 
-	err := p.implementor.Start()
+	err = p.implementor.Start()
 	if err != nil {
-		p.outputError("start error: %+v", err)
+		p.logError(logPhaseStart, "start error", "error", err)
 		return err
 	}
+	p.startTime = time.Now()
+
+	// 任务已经开始，运行失败或者发生 panic 时更新任务失败状态，避免任务一直处于开始状态
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("run panic: %v", r)
+			p.logError(logPhaseEnd, "run panic", "error", err, "stack", string(debug.Stack()))
+		}
+		if err == nil || p.ended {
+			return
+		}
+		p.saveCheckpoint() // 保留已经完成的行，重新运行时继续
+		p.metrics.TaskEnded(TaskStatusFailed, time.Since(p.startTime))
+		if failer, ok := p.implementor.(Failer); ok {
+			if err1 := failer.Failed(err); err1 != nil {
+				p.logError(logPhaseEnd, "failed error", "error", err1, "cause", err)
+			}
+		}
+	}()
 
+	return p.run()
+}
+
+// 运行扫描任务
+func (p *taskScheduler) run() (err error) {
 	f := &File{}
+	openTime := time.Now()
 	f, err = p.implementor.OpenFile()()
+	p.metrics.FileOpened(time.Since(openTime))
 	if err != nil {
-		p.outputError("open file error: %+v", err)
+		p.logError(logPhaseOpen, "open file error", "error", err)
 		return err
 	}
 
 	defer func() {
-		if f == nil {
-			return
-		}
 		if err1 := f.Close(); err1 != nil {
-			p.outputError("close file error: %+v", err1)
+			p.logWarn(logPhaseEnd, "close file error", "error", err1)
 		}
 	}()
 
-	// 设置转换结构字段数量
-	if p.transferStruct.fieldNum == 0 {
-		p.setTransferStruct()
+	// 选择工作表并设置转换结构字段数量，同一个工作表不能添加多次
+	sheetNames := make(map[string]struct{}, len(p.sheets))
+	for _, sheet := range p.sheets {
+		if err = sheet.open(f); err != nil {
+			p.logError(logPhaseOpen, "open sheet error", "error", err)
+			return err
+		}
+		if _, ok := sheetNames[sheet.sheetName]; ok {
+			err = fmt.Errorf("sheet %q is added more than once. ", sheet.sheetName)
+			p.logError(logPhaseOpen, "open sheet error", "error", err)
+			return err
+		}
+		sheetNames[sheet.sheetName] = struct{}{}
 	}
 
-	// 读取行数据
-	if err = p.readRows(f); err != nil {
-		p.outputError("read rows error: %+v", err)
+	// 加载检查点
+	if err = p.loadCheckpoint(); err != nil {
+		p.logError(logPhaseCheckpoint, "load checkpoint error", "error", err)
 		return err
 	}
 
-	// 关闭文件对象
-	_ = f.Close()
-	f = nil
+	// 预扫描行数据：统计总行数、记录头部行、统计唯一列映射行数量
+	p.rowsCount = 0
+	hasDataRows := false
+	for _, sheet := range p.sheets {
+		if err = sheet.scanRows(f); err != nil {
+			sheet.logError(logPhaseScan, "scan rows error", "error", err)
+			// 预扫描时上下文被取消，没有处理任何行
+			if p.ctx.Err() != nil {
+				if err1 := p.end(newErrorMessages(FileFormatXlsx, CSVOptions{}, false, p.localizer), 0, 0, TaskStatusCancelled); err1 != nil {
+					p.logError(logPhaseEnd, "end error", "error", err1)
+				}
+			}
+			return err
+		}
+		p.rowsCount += sheet.rowsCount
+		hasDataRows = hasDataRows || sheet.hasDataRows()
+	}
 
-	// 可能是个空文件
-	if p.rowsCount <= p.skipRowNum {
-		p.outputError("this is empty file. ")
+	// 可能是个空文件，没有行需要处理，直接结束任务
+	if !hasDataRows {
+		p.logWarn(logPhaseScan, "this is empty file")
+		status := TaskStatusFinished
+		if p.dryRun {
+			status = TaskStatusDryRun
+		}
+		if err = p.end(newErrorMessages(FileFormatXlsx, CSVOptions{}, false, p.localizer), 0, 0, status); err != nil {
+			p.logError(logPhaseEnd, "end error", "error", err)
+			return err
+		}
+		p.deleteCheckpoint()
 		return nil
 	}
 
-	// 设置唯一列映射行数量
-	if err = p.setUniqueColumnMapRowNum(); err != nil {
-		p.outputError("set unique column map num error: %+v", err)
+	// 任务结束状态
+	status := TaskStatusFinished
+
+	// 错误消息，错误文件中每个工作表对应一个错误工作表
+	format, csvOptions := p.errorFileFormat.fileFormat(f)
+	if len(p.sheets) > 1 || p.errorFileFormat == ErrorFileFormatCopy && p.errorFileTpl != nil {
+		format, csvOptions = FileFormatXlsx, CSVOptions{} // csv/tsv 只能有一个工作表，模板为 xlsx
+	}
+	p.errMessages = newErrorMessages(format, csvOptions, p.errorRowNumber, p.localizer)
+	for _, sheet := range p.sheets {
+		sheet.errMessages = p.errMessages.addSheet(sheet.sheetName)
+		sheet.batch = newSubmitBatch(sheet)
+	}
+
+	// 完成行数
+	p.doneCount, p.progressDoneCount = 0, 0
+	if p.progressInterval, p.progressFn = p.implementor.Progress(); p.progressInterval <= 0 {
+		p.progressInterval = 100 // 默认 100
+	}
+
+	// 从检查点继续时恢复完成行数和错误
+	p.restoreCheckpoint()
+
+	// 并发提交时启动工作池，提前退出时也需要等待已经分发的批次
+	p.startPool()
+	defer p.stopPool()
+
+	// 按顺序读取每个工作表，明细工作表随主表读取
+	for _, sheet := range p.sheets {
+		if sheet.master != nil {
+			continue
+		}
+		if err = sheet.readRows(f); err != nil {
+			return err
+		}
+		if p.ctx.Err() != nil {
+			status = TaskStatusCancelled
+			break
+		}
+	}
+
+	// 等待所有批次提交完成
+	p.stopPool()
+
+	// 上下文在提交过程中被取消，部分批次没有提交
+	if p.ctx.Err() != nil {
+		status = TaskStatusCancelled
+	} else if p.dryRun {
+		status = TaskStatusDryRun
+	}
+
+	doneCount := p.doneCount            // 实际完成行数
+	errorCount := p.errMessages.Count() // 错误行数
+
+	for _, sheet := range p.sheets {
+		sheet.errMessages.setHeader(sheet.headerRows, sheet.maxColumnNum, sheet.skipRowNum)
+	}
+	if errorCount > 0 && p.errorFileFormat == ErrorFileFormatCopy && format == FileFormatXlsx {
+		p.copyErrorFileBase(f)
+	}
+	buildTime := time.Now()
+	err = p.errMessages.Build()
+	p.metrics.ErrorFileBuilt(time.Since(buildTime))
+	if err != nil {
+		p.logError(logPhaseBuild, "build error file error", "error", err)
+	}
+
+	p.logInfo(logPhaseEnd, "import end", "status", status, "total", p.rowsCount, "done_count", doneCount, "error_count", errorCount)
+
+	// 取消时保存检查点，重新运行时继续
+	if status == TaskStatusCancelled {
+		p.saveCheckpoint()
+	}
+
+	err = p.end(p.errMessages, doneCount, errorCount, status)
+	if err != nil {
+		p.logError(logPhaseEnd, "end error", "error", err)
 		return err
 	}
 
+	if status == TaskStatusCancelled {
+		return p.ctx.Err()
+	}
+
+	p.deleteCheckpoint()
+	return nil
+}
+
+// 读取工作表的行数据并分发提交，读取结束后提交剩余的批次
+func (s *sheetScheduler) readRows(f *File) (err error) {
+	// 先读取明细工作表，读取结束后没有关联到主表行的明细行写入错误文件
+	if s.detail != nil {
+		if err = s.detail.read(f); err != nil {
+			return err
+		}
+		defer func() {
+			if err == nil && s.task.ctx.Err() == nil {
+				s.detail.dispatchOrphans()
+			}
+		}()
+	}
+
+	// 没有数据行的工作表只写入头部行到错误文件
+	if !s.hasDataRows() {
+		s.logWarn(logPhaseScan, "this is empty sheet")
+		return nil
+	}
+
 	// 记录表头信息, 因为上面判断了行数不能小于等于跳过行数，所以这里直接用 0 取值
-	p.headerFirstData.data = p.rows[0]
-	p.headerFirstData.num = len(p.rows[0])
+	s.headerFirstData.data = s.headerRows[0]
+	s.headerFirstData.num = len(s.headerRows[0])
 
-	// 空行
-	emptyRowNum := 0
+	// 根据表头解析字段映射的列
+	var columnNum int
+	if columnNum, err = s.transferStruct.resolveColumns(s.headerFirstData.data); err != nil {
+		s.logError(logPhaseResolve, "resolve columns error", "error", err)
+		return err
+	}
+	if columnNum > s.maxColumnNum {
+		s.maxColumnNum = columnNum
+	}
 
-	// 错误消息
-	errMessages := newErrorMessages()
+	var rowIterator RowIterator
+	if rowIterator, err = f.NewRowIterator(s.sheetName); err != nil {
+		s.logError(logPhaseRead, "rows error", "error", err)
+		return err
+	}
+	defer func() {
+		if err1 := rowIterator.Close(); err1 != nil {
+			s.logWarn(logPhaseRead, "close rows error", "error", err1)
+		}
+	}()
 
-	// 完成行数
-	doneCount := 0
+	for i := 0; rowIterator.Next(); i++ {
+		// 上下文被取消，停止读取
+		if s.task.ctx.Err() != nil {
+			break
+		}
+
+		// 头部行已经在预扫描时记录，检查点中已经完成的行不再处理
+		if i < s.skipRowNum || s.isCheckpointDone(i) {
+			continue
+		}
+
+		var cells []string
+		if cells, err = rowIterator.Columns(); err != nil {
+			s.logError(logPhaseRead, "columns error", "error", err, "row_index", i)
+			return err
+		}
 
-	for i := p.skipRowNum; i < p.rowsCount; i++ {
 		// 是空行则跳过
-		if isEmpty(p.rows[i]) {
-			emptyRowNum++
+		if isEmpty(cells) {
 			// 空行不处理，不认为是失败操作
 			continue
 		}
 
 		// 解析数据
-		iRowData := p.parseRowData(p.rows[i])
+		s.task.metrics.RowsRead(1)
+		if s.checkpoint != nil {
+			s.checkpoint.read(i)
+		}
+		iRowData, parseErrs := s.parseRowData(i, cells)
+
+		// 明细行暂存，等待主表行关联
+		if s.master != nil {
+			s.master.detail.append(iRowData, i, cells, parseErrs)
+			continue
+		}
 
-		// rowsData：即将被提交的行数据
-		var rowsData IRows
+		// 关联明细行
+		var details *rows
+		if s.detail != nil {
+			var linkErr error
+			details, linkErr = s.detail.link(i, cells, s.task.localizer)
+			parseErrs.Append(linkErr)
+		}
 
 		// 获取唯一列的key
-		if index, ok := p.getUniqueColumnIndex(p.rows[i]); ok {
+		if group, ok := s.getUniqueColumn(cells); ok {
 			// 暂存 row 到 groupRows 中
 			// 减少需要合并的行数据量
-			p.groupRows.rows[index].rowsData.Append(iRowData, i)
-			p.groupRows.rows[index].mergeNum--
+			rowData := group.rowsData.appendRow(iRowData, i, cells)
+			rowData.SetErrs(parseErrs...)
+			rowData.details = details
+			group.mergeNum--
 
 			// 判断该行数据是否可以封包，不可以则继续等待封包
-			if p.groupRows.rows[index].mergeNum > 0 {
+			if group.mergeNum > 0 {
 				continue
 			}
 
 			// 当前需要合并的行数据是0
 			// 取出暂存在 groupRows 中的 rows
-			rowsData = p.groupRows.rows[index].rowsData
-			p.groupRows.rows[index] = nil
-		} else {
-			rowsData = newRows() // 初始化为空的
-			rowsData.Append(iRowData, i)
-		}
-
-		/*
-			场景：
-				1. 逐行提交	-> SubmitForEach -> error SetRowErr
-				2. 一次性提交	-> Submit -> error SetRowErr
-		*/
-
-		// 提交数据
-		p.implementor.Submit(rowsData)
-
-		// 从已提交的 rows 中尝试获取错误消息并写入到 errs
-		if rowsData.IsErr() {
-			for j := 0; j < rowsData.Count(); j++ {
-				rowData := rowsData.GetRow(j)
-				formIndex := rowData.GetFormIndex()
-				var printErr error
-				if errs := rowData.GetErrs(); len(errs) > 0 {
-					printErr = errs.PrintError()
-					p.outputError("Submit error: %+v, form index: %d", printErr, formIndex)
-				}
-				if p.groupRows.errorWriteBackMode.errorWriteBackModeIsAny() || printErr != nil {
-					errMessages.Append(formIndex, printErr)
-				}
-			}
+			s.dispatch(group.rowsData)
+			group.rowsData = nil
+			continue
 		}
 
-		doneCount = i - p.skipRowNum - emptyRowNum // 完成行数
-		doneInterval, fn := p.implementor.Progress()
-		if doneInterval <= 0 {
-			doneInterval = 100 // 默认 100
-		}
-		if fn != nil && doneCount%doneInterval == 0 {
-			if err = fn(p.rowsCount, doneCount); err != nil {
-				p.outputError("heart beat error: %+v", err)
-			}
-		}
+		rowsData := newRows() // 初始化为空的
+		rowData := rowsData.appendRow(iRowData, i, cells)
+		rowData.SetErrs(parseErrs...)
+		rowData.details = details
+		s.dispatch(rowsData)
 	}
 
-	doneCount = p.rowsCount - p.skipRowNum - emptyRowNum // 实际完成行数
-	errorCount := errMessages.Count()                    // 错误行数
-
-	err = errMessages.Build(p.rows, p.maxColumnNum, p.skipRowNum)
-	if err != nil {
-		p.outputError("write error: %+v", err)
+	if err = rowIterator.Error(); err != nil {
+		s.logError(logPhaseRead, "read rows error", "error", err)
+		return err
 	}
 
-	err = p.implementor.End(errMessages, doneCount, errorCount)
+	// 提交剩余的批次
+	s.flush()
+	return nil
+}
+
+// 结束任务，End 发生 panic 时转换为错误
+func (p *taskScheduler) end(errs IErrorMessages, doneCount int, errorCount int, status TaskStatus) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("end panic: %v", r)
+			p.logError(logPhaseEnd, "end panic", "error", err, "stack", string(debug.Stack()))
+		}
+	}()
+
+	if ender, ok := p.implementor.(StatusEnder); ok {
+		err = ender.EndWithStatus(errs, doneCount, errorCount, status)
+	} else {
+		err = p.implementor.End(errs, doneCount, errorCount)
+	}
 	if err != nil {
-		p.outputError("start error: %+v", err)
 		return err
 	}
-
+	p.ended = true
+	p.metrics.TaskEnded(status, time.Since(p.startTime))
 	return nil
 }
 
 // 是否开启分组行
-func (p *taskScheduler) isEnableGroupRows() bool {
-	return p.groupRows.indexesLen > 0
+func (s *sheetScheduler) isEnableGroupRows() bool {
+	return s.groupRows.indexesLen > 0
 }
 
-// 读取行数据
-func (p *taskScheduler) readRows(file *File) error {
-	// 获取工作表
-	if p.sheetName = file.GetSheetName(0); p.sheetName == "" {
-		p.outputError("sheet is empty. ")
-		return errors.New("sheet is empty. ")
-	}
-
-	var err error
-	p.rows, err = file.GetRows(p.sheetName)
+// 预扫描行数据。
+// 只保留头部行、总行数以及唯一列的合并数量，不会把整表数据留在内存中
+func (s *sheetScheduler) scanRows(file *File) error {
+	rowIterator, err := file.NewRowIterator(s.sheetName)
 	if err != nil {
-		p.outputError("GetRows error: %s", err.Error())
+		s.logError(logPhaseScan, "rows error", "error", err)
 		return err
 	}
+	defer func() {
+		if err1 := rowIterator.Close(); err1 != nil {
+			s.logWarn(logPhaseScan, "close rows error", "error", err1)
+		}
+	}()
 
-	p.rowsCount = len(p.rows)
-	return nil
-}
+	s.headerRows = make([][]string, 0, s.skipRowNum)
+	s.rowsCount = 0
+	for ; rowIterator.Next(); s.rowsCount++ {
+		if s.task.ctx.Err() != nil {
+			return s.task.ctx.Err()
+		}
 
-// 设置唯一列映射行数量
-func (p *taskScheduler) setUniqueColumnMapRowNum() error {
-	if !p.isEnableGroupRows() {
-		return nil
-	}
+		// 不需要头部行和唯一列的时候只需要计数
+		if s.rowsCount >= s.skipRowNum && !s.isEnableGroupRows() {
+			continue
+		}
 
-	keyNum := make(map[string]int)             // map[key]num
-	keyStartIndex := make(map[string]struct{}) // map[key]row index
-	ok := false
-	groupRowIndex := 0
-	for i := p.skipRowNum; i < p.rowsCount; i++ {
-		keys := make([]string, p.groupRows.indexesLen)
-		for j := 0; j < p.groupRows.indexesLen; j++ {
-			if p.rows == nil || p.rows[i] == nil {
-				continue
-			}
-			keys[j] = strings.TrimSpace(p.rows[i][j])
+		var cells []string
+		if cells, err = rowIterator.Columns(); err != nil {
+			s.logError(logPhaseScan, "columns error", "error", err, "row_index", s.rowsCount)
+			return err
 		}
-		key := strings.Join(keys, ":")
-		keyNum[key]++
 
-		p.groupRows.rows = append(p.groupRows.rows, nil) // 默认给nil, 只有被需要被找寻的row才会被初始化数据
-		_, ok = keyStartIndex[key]
-		if ok {
+		if s.rowsCount < s.skipRowNum {
+			s.headerRows = append(s.headerRows, cells)
 			continue
 		}
-		keyStartIndex[key] = struct{}{}
-		groupRowIndex = i - p.skipRowNum
-		p.groupRows.keyMapIndex[key] = groupRowIndex
-		p.groupRows.rows[groupRowIndex] = &uniqueColumn{
-			rowsData: newRows(),
-			mergeNum: 0,
+
+		// 空行和检查点中已经完成的行不会被提交，也不参与合并
+		if isEmpty(cells) || s.isCheckpointDone(s.rowsCount) {
+			continue
 		}
-	}
 
-	var num int
-	for key, index := range p.groupRows.keyMapIndex {
-		if num, ok = keyNum[key]; ok {
-			p.groupRows.rows[index].mergeNum = num
+		// 统计唯一列映射行数量
+		key, ok := s.uniqueColumnKey(cells)
+		if !ok {
+			continue
+		}
+		group, ok := s.groupRows.keyMapRows[key]
+		if !ok {
+			group = &uniqueColumn{
+				rowsData: newRows(),
+				mergeNum: 0,
+			}
+			s.groupRows.keyMapRows[key] = group
 		}
+		group.mergeNum++
 	}
 
-	return nil
+	return rowIterator.Error()
 }
 
 // 获取唯一列的key
-func (p *taskScheduler) getUniqueColumnIndex(rowData []string) (index int, ok bool) {
+func (s *sheetScheduler) uniqueColumnKey(rowData []string) (key string, ok bool) {
 	rowDataCount := len(rowData)
-	if !p.isEnableGroupRows() {
-		return 0, false
+	if !s.isEnableGroupRows() {
+		return "", false
 	}
 
 	// 根据索引分组的数量为1的话只需要直接拿索引从数据中返回
-	if p.groupRows.indexesLen == 1 {
+	if s.groupRows.indexesLen == 1 {
 		// 避免越界，索引超过行数据数量可能不是一个
-		if p.groupRows.indexes[0] >= rowDataCount {
-			return 0, false
-		}
-		if index, ok = p.groupRows.keyMapIndex[rowData[p.groupRows.indexes[0]]]; ok {
-			return index, true
+		if s.groupRows.indexes[0] >= rowDataCount {
+			return "", false
 		}
-		return 0, false
+		return strings.TrimSpace(rowData[s.groupRows.indexes[0]]), true
 	}
 
-	keys := make([]string, p.groupRows.indexesLen)
-	for i := 0; i < p.groupRows.indexesLen; i++ {
-		if p.groupRows.indexes[i] >= rowDataCount {
-			return 0, false
+	keys := make([]string, s.groupRows.indexesLen)
+	for i := 0; i < s.groupRows.indexesLen; i++ {
+		if s.groupRows.indexes[i] >= rowDataCount {
+			return "", false
 		}
-		keys[i] = strings.TrimSpace(rowData[p.groupRows.indexes[i]])
+		keys[i] = strings.TrimSpace(rowData[s.groupRows.indexes[i]])
 	}
-	if index, ok = p.groupRows.keyMapIndex[strings.Join(keys, ":")]; ok {
-		return index, true
+	return strings.Join(keys, ":"), true
+}
+
+// 获取唯一列的分组行
+func (s *sheetScheduler) getUniqueColumn(rowData []string) (group *uniqueColumn, ok bool) {
+	key, ok := s.uniqueColumnKey(rowData)
+	if !ok {
+		return nil, false
 	}
-	return 0, false
+	if group, ok = s.groupRows.keyMapRows[key]; !ok || group.rowsData == nil {
+		return nil, false
+	}
+	return group, true
 }
 
-func (p *taskScheduler) SetUniqueColumn(idxes ...int) error {
+func (s *sheetScheduler) SetUniqueColumn(idxes ...int) error {
 	if len(idxes) == 0 {
 		return nil
 	}
-	p.setTransferStruct()
+	// 明细行随主表行提交，不能按唯一列分组
+	if s.master != nil {
+		return errors.New("set unique column error: detail sheet does not support unique columns. ")
+	}
+	if err := s.setTransferStruct(); err != nil {
+		return err
+	}
 
 	sort.Ints(idxes)  // 排序，从小到大，便于列计数时使用列迭代器的指针下移次数
 	var indexes []int // 去重后的 index，避免取相同列数据作为 key
@@ -375,100 +635,221 @@ func (p *taskScheduler) SetUniqueColumn(idxes ...int) error {
 		if idxes[i] < 0 {
 			return errors.New("set unique column error: exceeds the min number of TransferStruct. ")
 		}
-		if idxes[i] >= p.transferStruct.fieldNum {
+		if idxes[i] >= s.maxColumnNum {
 			return errors.New("set unique column error: exceeds the max number of TransferStruct. ")
 		}
 		indexes = append(indexes, idxes[i])
 	}
 
-	p.groupRows.indexes = indexes
-	p.groupRows.indexesLen = len(p.groupRows.indexes)
+	s.groupRows.indexes = indexes
+	s.groupRows.indexesLen = len(s.groupRows.indexes)
 
 	return nil
 }
 
-func (p *taskScheduler) SetErrorWriteBackMode(mode ErrorWriteBackMode) {
+func (s *sheetScheduler) SetErrorWriteBackMode(mode ErrorWriteBackMode) {
 	if mode != ErrorWriteBackModeAnyRow && mode != ErrorWriteBackModeAssignRow {
 		return
 	}
 
-	p.groupRows.errorWriteBackMode = mode
+	s.groupRows.errorWriteBackMode = mode
+}
+
+func (p *taskScheduler) SetUniqueColumn(indexes ...int) error {
+	return p.sheets[0].SetUniqueColumn(indexes...)
+}
+
+func (p *taskScheduler) SetErrorWriteBackMode(mode ErrorWriteBackMode) {
+	p.sheets[0].SetErrorWriteBackMode(mode)
+}
+
+func (p *taskScheduler) SetSheet(selector SheetSelector) {
+	if selector == nil {
+		return
+	}
+
+	p.sheets[0].selector = selector
+}
+
+func (p *taskScheduler) SetDetailSheet(selector SheetSelector, implementor DetailImplementor, skipRowNum int, masterColumn, detailColumn int) SheetScheduler {
+	return p.sheets[0].SetDetailSheet(selector, implementor, skipRowNum, masterColumn, detailColumn)
 }
 
-func (p *taskScheduler) setTransferStruct() {
-	p.transferStruct.typeOf = reflect.TypeOf(p.implementor.TransferStruct())
-	if p.transferStruct.typeOf.Kind() == reflect.Ptr {
-		p.transferStruct.typeOf = p.transferStruct.typeOf.Elem()
+func (p *taskScheduler) AddSheet(selector SheetSelector, implementor SheetImplementor, skipRowNum int) SheetScheduler {
+	sheet := newSheetScheduler(p, selector, implementor, skipRowNum)
+	p.sheets = append(p.sheets, sheet)
+	return sheet
+}
+
+func (p *taskScheduler) SetSubmitInvalidRows(submit bool) {
+	p.submitInvalid = submit
+}
+
+func (p *taskScheduler) SetLocale(locale Locale) {
+	if locale == "" {
+		return
 	}
 
-	p.transferStruct.fieldNum = p.transferStruct.typeOf.NumField() // 转换结构体字段数量
-	p.maxColumnNum = p.transferStruct.fieldNum                     // 初始化最大列数
+	p.localizer.locale = locale
+}
+
+func (p *taskScheduler) SetMessages(messages Messages) {
+	p.localizer.overrides = messages
 }
 
-func (p *taskScheduler) parseRowData(rowData []string) (iRowData interface{}) {
-	iRowData = p.implementor.TransferStruct()
+func (p *taskScheduler) SetLogger(logger Logger, args ...interface{}) {
+	// logger 为 nil 时保留原有日志，args 仍然生效
+	if logger != nil {
+		p.logger = logger
+	}
+	p.logAttrs = args
+}
+
+func (p *taskScheduler) SetMetrics(metrics Metrics) {
+	if metrics == nil {
+		return
+	}
+
+	p.metrics = metrics
+}
+
+func (p *taskScheduler) SetContext(ctx context.Context) {
+	if ctx == nil {
+		return
+	}
+
+	p.ctx = ctx
+}
+
+func (p *taskScheduler) SetCheckpointStore(store CheckpointStore) {
+	p.checkpointStore = store
+}
+
+func (p *taskScheduler) SetDryRun(dryRun bool) {
+	p.dryRun = dryRun
+}
+
+func (p *taskScheduler) SetBatchSize(n int) {
+	if n <= 0 {
+		return
+	}
+
+	p.batchSize = n
+}
+
+func (p *taskScheduler) SetConcurrency(n int) {
+	if n <= 0 {
+		return
+	}
+
+	p.concurrency = n
+}
+
+func (p *taskScheduler) SetErrorFileFormat(format ErrorFileFormat) {
+	if format != ErrorFileFormatXlsx && format != ErrorFileFormatSource && format != ErrorFileFormatCopy {
+		return
+	}
+
+	p.errorFileFormat = format
+}
+
+func (p *taskScheduler) SetErrorFileTemplate(open OpenFileFunc) {
+	p.errorFileTpl = open
+}
+
+func (p *taskScheduler) SetErrorFileRowNumber(enable bool) {
+	p.errorRowNumber = enable
+}
+
+func (s *sheetScheduler) setTransferStruct() error {
+	typeOf := reflect.TypeOf(s.implementor.TransferStruct())
+	if typeOf.Kind() == reflect.Ptr {
+		typeOf = typeOf.Elem()
+	}
+
+	transferStruct, err := newTransferStruct(typeOf)
+	if err != nil {
+		return err
+	}
+
+	s.transferStruct = transferStruct
+	s.maxColumnNum = s.transferStruct.staticColumnNum() // 初始化最大列数
+	return nil
+}
+
+// 解析行数据并根据 validate tag 校验，单元格解析或校验失败时返回单元格错误，解析失败的字段保持零值
+func (s *sheetScheduler) parseRowData(rowIndex int, rowData []string) (iRowData interface{}, errs Errors) {
+	iRowData = s.implementor.TransferStruct()
 	va := reflect.ValueOf(iRowData)
 	if va.Kind() == reflect.Ptr {
 		va = va.Elem()
 	}
 
-	// 转换结构体字段数量
 	rowDataCount := len(rowData)
 
-	// 补全空列
-	if rowDataCount < p.transferStruct.fieldNum {
-		rowData = append(rowData, make([]string, p.transferStruct.fieldNum-rowDataCount)...)
-		rowDataCount = p.transferStruct.fieldNum
-	}
-
-	for i := 0; i < rowDataCount; i++ {
-		// 超过了定义的结构体列数, 后面的列数将不再做任何解析. 直接返回该行的数据
-		if i+1 > p.transferStruct.fieldNum {
-			return iRowData
+	for _, field := range s.transferStruct.fields {
+		// 超过了行数据的列按空列处理
+		var data string
+		if field.column < rowDataCount {
+			data = strings.TrimSpace(rowData[field.column])
 		}
+
 		//style, _ := xlsx.NewStyle(`{"number_format": 21}`)
 		//xlsx.SetCellStyle("Sheet1", "B2", "B2", style)
-		data := strings.TrimSpace(rowData[i])
-		switch p.transferStruct.typeOf.Field(i).Type.Kind() {
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			n64Data, _ := strconv.ParseInt(data, 10, 64)
-			va.Field(i).SetInt(n64Data)
-		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-			n64Data, _ := strconv.ParseUint(data, 10, 64)
-			va.Field(i).SetUint(n64Data)
-		case reflect.Float32, reflect.Float64:
-			f64Data, _ := strconv.ParseFloat(data, 64)
-			va.Field(i).SetFloat(f64Data)
-		case reflect.String:
-			va.Field(i).SetString(data)
-		case reflect.Struct: // 可能是: 附加列
-			switch p.transferStruct.typeOf.Field(i).Type {
-			case extraColumnType: // 附加列
-
-				// 附加列的时候。需要将最大列数往后移动。这里涉及 error 列的数据写入
-				if rowDataCount > p.maxColumnNum {
-					p.maxColumnNum = rowDataCount
+		fieldValue := va.Field(field.index)
+		if field.typeOf == extraColumnType { // 附加列
+
+			// 附加列的时候。需要将最大列数往后移动。这里涉及 error 列的数据写入
+			if rowDataCount > s.maxColumnNum {
+				s.maxColumnNum = rowDataCount
+			}
+
+			pData := ExtraColumn{}
+			for j := field.column; j < s.headerFirstData.num; j++ {
+				// 避免存在空列，但实际上是模板问题（中间的空列也将被忽略）
+				if strings.TrimSpace(s.headerFirstData.data[j]) == "" {
+					continue
 				}
+				pData.headerData = append(pData.headerData, s.headerFirstData.data[j])
 
-				pData := ExtraColumn{}
-				for j := i; j < p.headerFirstData.num; j++ {
-					// 避免存在空列，但实际上是模板问题（中间的空列也将被忽略）
-					if strings.TrimSpace(p.headerFirstData.data[j]) == "" {
-						continue
-					}
-					pData.headerData = append(pData.headerData, p.headerFirstData.data[j])
-
-					// 附加列数据获取
-					var extraColumnData string
-					if j <= len(rowData)-1 {
-						extraColumnData = rowData[j]
-					}
-
-					pData.data = append(pData.data, strings.TrimSpace(extraColumnData))
+				// 附加列数据获取
+				var extraColumnData string
+				if j <= rowDataCount-1 {
+					extraColumnData = rowData[j]
 				}
-				va.Field(i).Set(reflect.ValueOf(pData))
+
+				pData.data = append(pData.data, strings.TrimSpace(extraColumnData))
 			}
+			fieldValue.Set(reflect.ValueOf(pData))
+			continue
+		}
+
+		if err := parseCell(fieldValue, data, field, s.task.localizer); err != nil {
+			errs.Append(s.newCellError(field, rowIndex, data, err))
+			continue
 		}
+
+		// 解析成功后校验
+		if err := field.validate(fieldValue, data, s.task.localizer); err != nil {
+			errs.Append(s.newCellError(field, rowIndex, data, err))
+		}
+	}
+	return iRowData, errs
+}
+
+// 单元格错误
+func (s *sheetScheduler) newCellError(field *transferField, rowIndex int, value string, err error) *CellError {
+	header := field.name
+	if field.column < s.headerFirstData.num && strings.TrimSpace(s.headerFirstData.data[field.column]) != "" {
+		header = strings.TrimSpace(s.headerFirstData.data[field.column])
+	}
+	cell, _ := excelize.CoordinatesToCellName(field.column+1, rowIndex+1)
+	return &CellError{
+		Field:  field.name,
+		Header: header,
+		Cell:   cell,
+		Column: field.column,
+		Value:  value,
+		Err:    err,
 	}
-	return iRowData
 }
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"log/slog"
+)
+
+// Logger 日志接口，与 *slog.Logger 兼容。args 为交替的 key、value 或者 slog.Attr
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// 日志的阶段
+const (
+	logPhaseStart      = "start"      // 任务开始
+	logPhaseOpen       = "open"       // 打开文件
+	logPhaseScan       = "scan"       // 预扫描
+	logPhaseResolve    = "resolve"    // 解析表头和中转结构体
+	logPhaseRead       = "read"       // 读取行数据
+	logPhaseSubmit     = "submit"     // 提交数据
+	logPhaseProgress   = "progress"   // 进度更新
+	logPhaseBuild      = "build"      // 生成错误文件
+	logPhaseEnd        = "end"        // 任务结束
+	logPhaseCheckpoint = "checkpoint" // 检查点
+	logPhaseCheck      = "check"      // 导入检查
+)
+
+// 默认日志
+func defaultLogger() Logger {
+	return slog.Default()
+}
+
+// 日志参数。附带任务标识、工作表和阶段，便于在日志中过滤出同一个任务，任务级别的日志没有工作表
+func (p *taskScheduler) logArgs(sheetName, phase string, args []interface{}) []interface{} {
+	res := make([]interface{}, 0, 6+len(p.logAttrs)+len(args))
+	res = append(res, "id", p.id)
+	if sheetName != "" {
+		res = append(res, "sheet", sheetName)
+	}
+	res = append(res, "phase", phase)
+	res = append(res, p.logAttrs...)
+	return append(res, args...)
+}
+
+func (p *taskScheduler) logInfo(phase, msg string, args ...interface{}) {
+	p.logger.Info(msg, p.logArgs("", phase, args)...)
+}
+
+func (p *taskScheduler) logWarn(phase, msg string, args ...interface{}) {
+	p.logger.Warn(msg, p.logArgs("", phase, args)...)
+}
+
+func (p *taskScheduler) logError(phase, msg string, args ...interface{}) {
+	p.logger.Error(msg, p.logArgs("", phase, args)...)
+}
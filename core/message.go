@@ -0,0 +1,163 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Locale 语言
+type Locale string
+
+const (
+	LocaleZhCN Locale = "zh-CN" // 简体中文
+	LocaleEnUS Locale = "en-US" // 英文
+)
+
+// Messages 消息目录，key 为消息的 key，value 为消息模板（fmt 格式，参数见默认消息）
+type Messages map[string]string
+
+// 消息的 key。校验失败的消息为 "validate." + 校验规则，例如 "validate.required"、"validate.max_len"；
+// 主表和明细工作表关联失败的消息为 "detail." + 关联原因，例如 "detail.orphan"
+const (
+	MessageErrorColumn     = "error_file.error_column"      // 错误文件中错误消息列的表头
+	MessageRowNumberColumn = "error_file.row_number_column" // 错误文件中原始行号列的表头
+	MessageCommentAuthor   = "error_file.comment_author"    // 错误文件中单元格批注的作者
+
+	MessageParseBool       = "parse.bool"        // 不是有效的布尔值
+	MessageParseInt        = "parse.int"         // 不是有效的整数
+	MessageParseIntRange   = "parse.int_range"   // 超出整数的范围
+	MessageParseUint       = "parse.uint"        // 不是有效的非负整数
+	MessageParseUintRange  = "parse.uint_range"  // 超出非负整数的范围
+	MessageParseFloat      = "parse.float"       // 不是有效的数字
+	MessageParseFloatRange = "parse.float_range" // 超出数字的范围
+	MessageParseDate       = "parse.date"        // 不是有效的日期
+
+	MessageCheckMaxRowNum        = "check.max_row_num"        // 见 MaxRowNumError
+	MessageCheckTemplate         = "check.template"           // 见 TemplateError
+	MessageCheckLargestRowNumber = "check.largest_row_number" // 见 LargestRowNumberError
+	MessageCheckEmptyFile        = "check.empty_file"         // 见 EmptyFile
+	MessageCheckUnknown          = "check.unknown"            // 未知错误，参数为原始错误
+)
+
+var (
+	catalogMu sync.RWMutex
+
+	// 内置的消息目录，其它语言通过 RegisterMessages 添加
+	catalog = map[Locale]Messages{
+		LocaleZhCN: {
+			MessageErrorColumn:     "错误提示",
+			MessageRowNumberColumn: "原始行号",
+			MessageCommentAuthor:   "错误提示",
+
+			MessageParseBool:       "不是有效的布尔值(是/否)",
+			MessageParseInt:        "不是有效的整数",
+			MessageParseIntRange:   "超出整数的范围",
+			MessageParseUint:       "不是有效的非负整数",
+			MessageParseUintRange:  "超出非负整数的范围",
+			MessageParseFloat:      "不是有效的数字",
+			MessageParseFloatRange: "超出数字的范围",
+			MessageParseDate:       "不是有效的日期",
+
+			"validate.required": "不能为空",
+			"validate.min":      "不能小于 %s",
+			"validate.max":      "不能大于 %s",
+			"validate.min_len":  "长度不能小于 %s",
+			"validate.max_len":  "长度不能大于 %s",
+			"validate.len":      "长度必须为 %s",
+			"validate.oneof":    "只能是 %s 其中之一",
+			"validate.regex":    "格式不正确",
+
+			"detail." + DetailReasonNoDetails:  "没有对应的明细行",
+			"detail." + DetailReasonDuplicated: "明细行已经关联到第 %d 行",
+			"detail." + DetailReasonInvalid:    "明细行存在错误",
+			"detail." + DetailReasonOrphan:     "没有对应的主表行 \"%s\"",
+
+			MessageCheckMaxRowNum:        "最大行数错误",
+			MessageCheckTemplate:         "导入文件与模板不一致",
+			MessageCheckLargestRowNumber: "超过最大行数",
+			MessageCheckEmptyFile:        "导入文件没有数据",
+			MessageCheckUnknown:          "未知错误：%s",
+		},
+		LocaleEnUS: {
+			MessageErrorColumn:     "Error",
+			MessageRowNumberColumn: "Row number",
+			MessageCommentAuthor:   "Error",
+
+			MessageParseBool:       "is not a valid boolean (yes/no)",
+			MessageParseInt:        "is not a valid integer",
+			MessageParseIntRange:   "is out of the integer range",
+			MessageParseUint:       "is not a valid non-negative integer",
+			MessageParseUintRange:  "is out of the non-negative integer range",
+			MessageParseFloat:      "is not a valid number",
+			MessageParseFloatRange: "is out of the number range",
+			MessageParseDate:       "is not a valid date",
+
+			"validate.required": "is required",
+			"validate.min":      "must not be less than %s",
+			"validate.max":      "must not be greater than %s",
+			"validate.min_len":  "length must not be less than %s",
+			"validate.max_len":  "length must not be greater than %s",
+			"validate.len":      "length must be %s",
+			"validate.oneof":    "must be one of %s",
+			"validate.regex":    "has an invalid format",
+
+			"detail." + DetailReasonNoDetails:  "has no detail rows",
+			"detail." + DetailReasonDuplicated: "detail rows are already linked to row %d",
+			"detail." + DetailReasonInvalid:    "detail rows have errors",
+			"detail." + DetailReasonOrphan:     "has no master row \"%s\"",
+
+			MessageCheckMaxRowNum:        string(MaxRowNumError),
+			MessageCheckTemplate:         string(TemplateError),
+			MessageCheckLargestRowNumber: string(LargestRowNumberError),
+			MessageCheckEmptyFile:        string(EmptyFile),
+			MessageCheckUnknown:          string(unknownError),
+		},
+	}
+)
+
+// RegisterMessages 注册语言的消息，覆盖已有的消息，也可以添加新的语言，例如 "ja-JP"。
+// 所有任务都会使用注册的消息，只需要在初始化时调用；没有翻译的 key 使用简体中文
+func RegisterMessages(locale Locale, messages Messages) {
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+
+	if catalog[locale] == nil {
+		catalog[locale] = make(Messages, len(messages))
+	}
+	for key, message := range messages {
+		catalog[locale][key] = message
+	}
+}
+
+// 按语言获取消息，依次使用覆盖的消息、语言的消息和简体中文的消息
+type localizer struct {
+	locale    Locale
+	overrides Messages
+}
+
+// 获取消息，没有找到时返回 key
+func (l localizer) message(key string) string {
+	if message, ok := l.overrides[key]; ok {
+		return message
+	}
+
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+	if message, ok := catalog[l.locale][key]; ok {
+		return message
+	}
+	if message, ok := catalog[LocaleZhCN][key]; ok {
+		return message
+	}
+	return key
+}
+
+// 获取消息并格式化，消息中没有格式化动词时忽略参数
+func (l localizer) sprintf(key string, args ...interface{}) string {
+	message := l.message(key)
+	if len(args) == 0 || !strings.Contains(message, "%") {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
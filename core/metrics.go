@@ -0,0 +1,33 @@
+package core
+
+import (
+	"time"
+)
+
+// Metrics 指标接口，用于统计导入的吞吐量、错误率和各阶段耗时。
+// 所有方法都需要保证并发安全：开启并发提交时 RowsRead、RowsFailed 和 RowsSubmitted 会在不同的 goroutine 中同时调用，
+// 多个任务共用同一个 Metrics 时任意方法都可能被同时调用
+type Metrics interface {
+	// FileOpened 打开文件（包含下载）的耗时
+	FileOpened(duration time.Duration)
+	// RowsRead 读取的数据行数，不包含头部行和空行
+	RowsRead(n int)
+	// RowsSubmitted 一次 Submit 提交的行数和耗时
+	RowsSubmitted(n int, duration time.Duration)
+	// RowsFailed 失败的行数
+	RowsFailed(n int)
+	// ErrorFileBuilt 生成错误文件的耗时
+	ErrorFileBuilt(duration time.Duration)
+	// TaskEnded 任务结束，duration 为任务开始到结束的耗时
+	TaskEnded(status TaskStatus, duration time.Duration)
+}
+
+// 不统计任何指标
+type nopMetrics struct{}
+
+func (nopMetrics) FileOpened(time.Duration)            {}
+func (nopMetrics) RowsRead(int)                        {}
+func (nopMetrics) RowsSubmitted(int, time.Duration)    {}
+func (nopMetrics) RowsFailed(int)                      {}
+func (nopMetrics) ErrorFileBuilt(time.Duration)        {}
+func (nopMetrics) TaskEnded(TaskStatus, time.Duration) {}
@@ -3,6 +3,7 @@ package core
 import (
 	"bytes"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/xuri/excelize/v2"
@@ -10,17 +11,42 @@ import (
 
 type OpenFileFunc func() (*File, error)
 
+// FileFormat 文件格式
+type FileFormat string
+
+const (
+	FileFormatXlsx FileFormat = "xlsx" // excel 工作簿
+	FileFormatCSV  FileFormat = "csv"  // 逗号分隔
+	FileFormatTSV  FileFormat = "tsv"  // 制表符分隔
+)
+
+// File 导入文件。
+// xlsx 文件可以直接使用 excelize.File 的方法；csv/tsv 文件没有 excelize.File，只能使用 File 自身定义的方法
 type File struct {
 	*excelize.File
+	format FileFormat
+	csv    *csvSource
+}
+
+// RowIterator 行迭代器
+type RowIterator interface {
+	// Next 是否存在下一行
+	Next() bool
+	// Columns 当前行的列数据
+	Columns() ([]string, error)
+	// Error 迭代过程中产生的错误
+	Error() error
+	// Close 关闭迭代器
+	Close() error
 }
 
 func newFile() *File {
-	return &File{excelize.NewFile()}
+	return &File{File: excelize.NewFile(), format: FileFormatXlsx}
 }
 
 func OpenLocalFile(filename string) (*File, error) {
 	file, err := excelize.OpenFile(filename)
-	return &File{file}, err
+	return &File{File: file, format: FileFormatXlsx}, err
 }
 
 func OpenLocalFileFunc(filename string) OpenFileFunc {
@@ -34,7 +60,7 @@ func OpenBytesFile(content []byte) (*File, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &File{file}, nil
+	return &File{File: file, format: FileFormatXlsx}, nil
 }
 
 func OpenBytesFileFunc(content []byte) OpenFileFunc {
@@ -43,10 +69,121 @@ func OpenBytesFileFunc(content []byte) OpenFileFunc {
 	}
 }
 
+// OpenBytesAutoFile 根据文件内容识别格式后打开，支持 xlsx、csv、tsv。csv/tsv 文件的编码自动识别
+func OpenBytesAutoFile(content []byte) (*File, error) {
+	return OpenBytesEncodingFile(content, EncodingAuto)
+}
+
+func OpenBytesAutoFileFunc(content []byte) OpenFileFunc {
+	return func() (*File, error) {
+		return OpenBytesAutoFile(content)
+	}
+}
+
+// OpenBytesEncodingFile 根据文件内容识别格式后打开，csv/tsv 文件使用指定的编码解码
+func OpenBytesEncodingFile(content []byte, encoding Encoding) (*File, error) {
+	if isWorkbook(content) {
+		return OpenBytesFile(content)
+	}
+
+	// 带 UTF-8 BOM 的文件生成的错误文件同样写入 BOM
+	writeBOM := bytes.HasPrefix(content, utf8BOM)
+	content, encoding, err := decodeContent(content, encoding)
+	if err != nil {
+		return nil, err
+	}
+	return openDecodedCSVFile(content, CSVOptions{
+		Comma:    detectTextFileFormat(content).comma(),
+		WriteBOM: writeBOM,
+		Encoding: encoding,
+	}), nil
+}
+
+func OpenBytesEncodingFileFunc(content []byte, encoding Encoding) OpenFileFunc {
+	return func() (*File, error) {
+		return OpenBytesEncodingFile(content, encoding)
+	}
+}
+
+// Format 文件格式
+func (p *File) Format() FileFormat {
+	if p.format == "" {
+		return FileFormatXlsx
+	}
+	return p.format
+}
+
+// isCSV 是否为分隔符文本文件
+func (p *File) isCSV() bool {
+	return p.csv != nil
+}
+
+// NewRowIterator 获取工作表的行迭代器
+func (p *File) NewRowIterator(sheet string) (RowIterator, error) {
+	if p.isCSV() {
+		return p.csv.rowIterator(sheet)
+	}
+	rows, err := p.File.Rows(sheet)
+	if err != nil {
+		return nil, err
+	}
+	return &xlsxRowIterator{rows}, nil
+}
+
+// GetSheetName 根据索引获取工作表名称，不存在时返回空字符串
+func (p *File) GetSheetName(index int) string {
+	if p.isCSV() {
+		if index != 0 {
+			return ""
+		}
+		return csvSheetName
+	}
+	return p.File.GetSheetName(index)
+}
+
+// GetSheetList 获取所有工作表名称
+func (p *File) GetSheetList() []string {
+	if p.isCSV() {
+		return []string{csvSheetName}
+	}
+	return p.File.GetSheetList()
+}
+
+func (p *File) Close() error {
+	if p.isCSV() {
+		return nil
+	}
+	return p.File.Close()
+}
+
+// WriteToBuffer 将文件内容写入到 buffer
+func (p *File) WriteToBuffer() (*bytes.Buffer, error) {
+	if p.isCSV() {
+		return p.csv.writeToBuffer()
+	}
+	return p.File.WriteToBuffer()
+}
+
 func (p *File) SaveAs(filename string) error {
+	if p.isCSV() {
+		buffer, err := p.WriteToBuffer()
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filename, buffer.Bytes(), 0644)
+	}
 	return p.File.SaveAs(filename)
 }
 
 func (p *File) SaveDefaultErrorFile() error {
-	return p.File.SaveAs(fmt.Sprintf("%s_error.xlsx", time.Now().Format("20060102150405")))
+	return p.SaveAs(fmt.Sprintf("%s_error.%s", time.Now().Format("20060102150405"), p.Format()))
+}
+
+// xlsx 行迭代器
+type xlsxRowIterator struct {
+	*excelize.Rows
+}
+
+func (p *xlsxRowIterator) Columns() ([]string, error) {
+	return p.Rows.Columns()
 }
@@ -1,5 +1,19 @@
 package core
 
+// TaskStatus 任务结束状态
+type TaskStatus string
+
+const (
+	// TaskStatusFinished 已完成：扫描了全表
+	TaskStatusFinished TaskStatus = "finished"
+	// TaskStatusCancelled 已取消：上下文被取消，只处理了部分行，错误文件只包含已处理行的错误
+	TaskStatusCancelled TaskStatus = "cancelled"
+	// TaskStatusDryRun 试运行完成：扫描了全表但没有提交，错误文件包含解析、校验失败的行
+	TaskStatusDryRun TaskStatus = "dry_run"
+	// TaskStatusFailed 运行失败：只用于指标统计，EndWithStatus 不会收到该状态，失败时调用 Failer 的 Failed
+	TaskStatusFailed TaskStatus = "failed"
+)
+
 // ErrorWriteBackMode 错误回写模式
 type ErrorWriteBackMode string
 
@@ -14,3 +28,25 @@ const (
 func (e ErrorWriteBackMode) errorWriteBackModeIsAny() bool {
 	return e == "" || e == ErrorWriteBackModeAnyRow
 }
+
+// ErrorFileFormat 错误文件格式
+type ErrorFileFormat string
+
+const (
+	// ErrorFileFormatXlsx xlsx：无论导入文件是什么格式，错误文件都为 xlsx
+	ErrorFileFormatXlsx ErrorFileFormat = "xlsx"
+	// ErrorFileFormatSource 与导入文件一致：导入 csv/tsv 文件时错误文件也为 csv/tsv
+	ErrorFileFormatSource ErrorFileFormat = "source"
+	// ErrorFileFormatCopy 复制导入的工作簿：保留列宽、数字格式、头部样式、冻结窗格、整列的数据验证下拉框和其它工作表，
+	// 只保留头部行和错误行，修改后可以直接重新上传。设置了 SetErrorFileTemplate 时复制模板，保留模板的数据验证下拉框，
+	// 导入 csv/tsv 文件且没有设置模板时与 ErrorFileFormatSource 一致
+	ErrorFileFormatCopy ErrorFileFormat = "copy"
+)
+
+// 根据导入文件获取错误文件格式和 csv 选项
+func (e ErrorFileFormat) fileFormat(file *File) (FileFormat, CSVOptions) {
+	if (e == ErrorFileFormatSource || e == ErrorFileFormatCopy) && file.isCSV() {
+		return file.Format(), file.csv.options
+	}
+	return FileFormatXlsx, CSVOptions{}
+}
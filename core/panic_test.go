@@ -0,0 +1,117 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 进度更新和 End 可以发生 panic 的实现者，记录进度更新次数和 Failed 的参数
+type panicImplementor struct {
+	*testImplementor
+	progressPanic bool // 进度更新时发生 panic
+	endPanic      bool // End 时发生 panic
+
+	progressCalls int
+	failedErr     error
+}
+
+func (p *panicImplementor) Progress() (doneInterval int, fn func(total, doneCount int) error) {
+	return 1, func(total, doneCount int) error {
+		p.progressCalls++
+		if p.progressPanic {
+			panic("progress")
+		}
+		return nil
+	}
+}
+
+func (p *panicImplementor) End(errs IErrorMessages, doneCount int, errorCount int) error {
+	if p.endPanic {
+		panic("end")
+	}
+	return p.testImplementor.End(errs, doneCount, errorCount)
+}
+
+func (p *panicImplementor) Failed(err error) error {
+	p.failedErr = err
+	return nil
+}
+
+func TestSubmitPanic(t *testing.T) {
+	tests := []struct {
+		name        string
+		concurrency int
+	}{
+		{"sync", 1},
+		{"pool", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			implementor := &testImplementor{
+				file: newTestFile(t, [][]interface{}{{"A", "B", "C"}, {"k1", 1, "x"}, {"k2", 2, "y"}, {"k3", 3, "z"}}),
+				check: func(row IRow) error {
+					if row.GetFormIndex() == 2 {
+						panic("boom")
+					}
+					return nil
+				},
+			}
+			task := newTestTask(implementor, 1)
+			task.SetConcurrency(tt.concurrency)
+			if err := task.Run(); err != nil {
+				t.Fatal(err)
+			}
+
+			// 发生 panic 的批次中的行设置为错误，其它行不受影响
+			if !implementor.ended || implementor.doneCount != 3 || implementor.errorCount != 1 {
+				t.Fatalf("ended %v, done count %d, error count %d, want true, 3 and 1", implementor.ended, implementor.doneCount, implementor.errorCount)
+			}
+			want := [][]string{{"A", "B", "C", "错误提示"}, {"k2", "2", "y", "submit panic: boom"}}
+			if got := readTestFile(t, implementor.errs.GetErrFile(), "Sheet1"); !reflect.DeepEqual(got, want) {
+				t.Errorf("error file got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestProgressPanic(t *testing.T) {
+	implementor := &panicImplementor{
+		testImplementor: &testImplementor{file: newTestFile(t, [][]interface{}{{"A", "B", "C"}, {"k1", 1, "x"}, {"k2", 2, "y"}})},
+		progressPanic:   true,
+	}
+	if err := newTestTask(implementor, 1).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 进度更新发生 panic 时任务继续运行，每完成一行更新一次进度
+	if implementor.progressCalls != 2 {
+		t.Errorf("progress calls got %d, want 2", implementor.progressCalls)
+	}
+	if !implementor.ended || implementor.doneCount != 2 || implementor.errorCount != 0 {
+		t.Errorf("ended %v, done count %d, error count %d, want true, 2 and 0", implementor.ended, implementor.doneCount, implementor.errorCount)
+	}
+	if implementor.failedErr != nil {
+		t.Errorf("failed got %v, want nil", implementor.failedErr)
+	}
+}
+
+func TestEndPanic(t *testing.T) {
+	implementor := &panicImplementor{
+		testImplementor: &testImplementor{file: newTestFile(t, [][]interface{}{{"A", "B", "C"}, {"k1", 1, "x"}})},
+		endPanic:        true,
+	}
+	err := newTestTask(implementor, 1).Run()
+	if err == nil || !strings.Contains(err.Error(), "end panic: end") {
+		t.Fatalf("got error %v, want end panic", err)
+	}
+
+	// End 发生 panic 时转换为错误，并调用 Failed 更新任务失败状态
+	if !errors.Is(implementor.failedErr, err) {
+		t.Errorf("failed got %v, want %v", implementor.failedErr, err)
+	}
+	if len(implementor.submitted()) != 1 {
+		t.Errorf("submitted got %v, want 1 row", implementor.submitted())
+	}
+}
@@ -0,0 +1,152 @@
+package core
+
+import (
+	"encoding"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// CellUnmarshaler 自定义单元格解析。
+// 中转结构体的字段（或字段的指针）实现该接口时，将使用 UnmarshalCell 解析单元格数据，
+// raw 为去除首尾空白后的单元格数据，空单元格也会调用；返回的错误会作为单元格错误写入该行
+type CellUnmarshaler interface {
+	UnmarshalCell(raw string) error
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+
+	// 默认的日期时间格式，tag 中未指定 layout 时按顺序尝试。
+	// 包含 excelize 对内置日期格式单元格格式化后的格式
+	defaultTimeLayouts = []string{
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		"2006-01-02",
+		"2006/01/02 15:04:05",
+		"2006/1/2 15:04:05",
+		"2006/01/02 15:04",
+		"2006/1/2 15:04",
+		"2006/01/02",
+		"2006/1/2",
+		"2006年01月02日",
+		"2006年1月2日",
+		"01-02-06",
+		"1/2/06 15:04",
+		time.RFC3339,
+	}
+
+	// 布尔值的文本
+	boolValues = map[string]bool{
+		"是": true, "否": false,
+		"y": true, "n": false,
+		"yes": true, "no": false,
+		"t": true, "f": false,
+		"true": true, "false": false,
+		"1": true, "0": false,
+	}
+)
+
+// 解析单元格数据到字段，空单元格保持零值，指针字段保持 nil。
+// 解析顺序：CellUnmarshaler -> time.Time -> encoding.TextUnmarshaler -> 基础类型
+func parseCell(fieldValue reflect.Value, data string, field *transferField, l localizer) error {
+	if fieldValue.Kind() == reflect.Ptr {
+		if data == "" {
+			return nil
+		}
+		elem := reflect.New(fieldValue.Type().Elem())
+		if err := parseCell(elem.Elem(), data, field, l); err != nil {
+			return err
+		}
+		fieldValue.Set(elem)
+		return nil
+	}
+
+	if fieldValue.CanAddr() {
+		if unmarshaler, ok := fieldValue.Addr().Interface().(CellUnmarshaler); ok {
+			return unmarshaler.UnmarshalCell(data)
+		}
+	}
+
+	if data == "" {
+		return nil
+	}
+
+	if fieldValue.Type() == timeType {
+		t, ok := parseTime(data, field.layouts)
+		if !ok {
+			return errors.New(l.message(MessageParseDate))
+		}
+		fieldValue.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	if fieldValue.CanAddr() {
+		if unmarshaler, ok := fieldValue.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return unmarshaler.UnmarshalText([]byte(data))
+		}
+	}
+
+	switch fieldValue.Kind() {
+	case reflect.Bool:
+		b, ok := boolValues[strings.ToLower(data)]
+		if !ok {
+			return errors.New(l.message(MessageParseBool))
+		}
+		fieldValue.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n64Data, err := strconv.ParseInt(data, 10, fieldValue.Type().Bits())
+		if err != nil {
+			return parseNumError(err, l, MessageParseInt, MessageParseIntRange)
+		}
+		fieldValue.SetInt(n64Data)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n64Data, err := strconv.ParseUint(data, 10, fieldValue.Type().Bits())
+		if err != nil {
+			return parseNumError(err, l, MessageParseUint, MessageParseUintRange)
+		}
+		fieldValue.SetUint(n64Data)
+	case reflect.Float32, reflect.Float64:
+		f64Data, err := strconv.ParseFloat(data, fieldValue.Type().Bits())
+		if err != nil {
+			return parseNumError(err, l, MessageParseFloat, MessageParseFloatRange)
+		}
+		fieldValue.SetFloat(f64Data)
+	case reflect.String:
+		fieldValue.SetString(data)
+	}
+	return nil
+}
+
+// 数字解析错误，超出范围时使用 rangeKey 的消息
+func parseNumError(err error, l localizer, key, rangeKey string) error {
+	if errors.Is(err, strconv.ErrRange) {
+		return errors.New(l.message(rangeKey))
+	}
+	return errors.New(l.message(key))
+}
+
+// 解析日期时间。
+// 依次尝试 layouts（未指定时使用默认格式），都失败时按 excel 日期序列号解析
+func parseTime(data string, layouts []string) (time.Time, bool) {
+	if len(layouts) == 0 {
+		layouts = defaultTimeLayouts
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, data, time.Local); err == nil {
+			return t, true
+		}
+	}
+
+	// excel 日期序列号，例如 45292 表示 2024-01-01
+	if serial, err := strconv.ParseFloat(data, 64); err == nil && serial > 0 {
+		if t, err := excelize.ExcelDateToTime(serial, false); err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local), true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,155 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 自定义解析的测试字段
+type testLevel int
+
+func (p *testLevel) UnmarshalCell(raw string) error {
+	switch raw {
+	case "高":
+		*p = 2
+	case "低", "":
+		*p = 1
+	default:
+		return errors.New("未知等级")
+	}
+	return nil
+}
+
+func TestParseCell(t *testing.T) {
+	type content struct {
+		Int   int64
+		Int8  int8
+		Uint  uint
+		Float float64
+		Bool  bool
+		Date  time.Time
+		Ptr   *int
+		Level testLevel
+	}
+
+	zhCN := localizer{locale: LocaleZhCN}
+	enUS := localizer{locale: LocaleEnUS}
+	tests := []struct {
+		field     string
+		data      string
+		layouts   []string
+		localizer localizer
+		want      interface{}
+		wantErr   string
+	}{
+		{field: "Int", data: "-12", want: int64(-12)},
+		{field: "Int", data: "12a", wantErr: "不是有效的整数"},
+		{field: "Int", data: "12a", localizer: enUS, wantErr: "is not a valid integer"},
+		{field: "Int8", data: "128", wantErr: "超出整数的范围"},
+		{field: "Uint", data: "-1", wantErr: "不是有效的非负整数"},
+		{field: "Float", data: "1.5", want: 1.5},
+		{field: "Float", data: "1,5", wantErr: "不是有效的数字"},
+		{field: "Bool", data: "是", want: true},
+		{field: "Bool", data: "No", want: false},
+		{field: "Bool", data: "对", wantErr: "不是有效的布尔值(是/否)"},
+		{field: "Date", data: "2024/1/2", want: time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)},
+		{field: "Date", data: "45292", want: time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)},
+		{field: "Date", data: "02.01.2024", layouts: []string{"02.01.2006"}, want: time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)},
+		{field: "Date", data: "2024-13-01", wantErr: "不是有效的日期"},
+		{field: "Ptr", data: "", want: (*int)(nil)},
+		{field: "Level", data: "", want: testLevel(1)},
+		{field: "Level", data: "高", want: testLevel(2)},
+		{field: "Level", data: "中", wantErr: "未知等级"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field+" "+tt.data, func(t *testing.T) {
+			if tt.localizer.locale == "" {
+				tt.localizer = zhCN
+			}
+			value := reflect.ValueOf(&content{}).Elem().FieldByName(tt.field)
+			err := parseCell(value, tt.data, &transferField{name: tt.field, layouts: tt.layouts}, tt.localizer)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := value.Interface(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCellError(t *testing.T) {
+	tests := []struct {
+		name          string
+		submitInvalid bool
+		submitted     []int
+	}{
+		{"invalid rows are not submitted", false, []int{1, 3}},
+		{"submit invalid rows", true, []int{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var invalid []int
+			implementor := &testImplementor{
+				file: newTestFile(t, [][]interface{}{
+					{"名称", "数量", "备注"},
+					{"k1", "1", "x"},
+					{"k2", "12a", "y"},
+					{"k3", "-1", "z"},
+				}),
+				check: func(row IRow) error {
+					if row.IsErr() {
+						invalid = append(invalid, row.GetFormIndex())
+						return nil
+					}
+					if row.GetData().(*testRow).B < 0 {
+						return errors.New("数量不能为负数")
+					}
+					return nil
+				},
+			}
+			task := newTestTask(implementor, 1)
+			task.SetSubmitInvalidRows(tt.submitInvalid)
+			if err := task.Run(); err != nil {
+				t.Fatal(err)
+			}
+			if got := implementor.submitted(); !reflect.DeepEqual(got, tt.submitted) {
+				t.Errorf("submitted got %v, want %v", got, tt.submitted)
+			}
+			// 提交时解析失败的行已经带有错误
+			if tt.submitInvalid && !reflect.DeepEqual(invalid, []int{2}) {
+				t.Errorf("invalid rows got %v, want [2]", invalid)
+			}
+			if implementor.doneCount != 3 || implementor.errorCount != 2 {
+				t.Errorf("done count %d, error count %d, want 3 and 2", implementor.doneCount, implementor.errorCount)
+			}
+
+			// 错误消息带有列表头和单元格坐标，数据保持原样
+			want := [][]string{
+				{"名称", "数量", "备注", "错误提示"},
+				{"k2", "12a", "y", `数量(B3) "12a" 不是有效的整数`},
+				{"k3", "-1", "z", "数量不能为负数"},
+			}
+			errFile := implementor.errs.GetErrFile()
+			if got := readTestFile(t, errFile, "Sheet1"); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+			comments, err := errFile.GetComments("Sheet1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(comments) != 1 || comments[0].Cell != "B2" || !strings.Contains(comments[0].Text, "不是有效的整数") {
+				t.Errorf("comments got %+v, want a comment on B2", comments)
+			}
+		})
+	}
+}
@@ -28,6 +28,8 @@ type IRow interface {
 	GetData() interface{}
 	// GetFormIndex 获取整表的索引
 	GetFormIndex() (formIndex int)
+	// GetDetails 获取明细行，设置了明细工作表时为关联的明细行，否则为空
+	GetDetails() IRows
 	// IsErr 是否错误
 	IsErr() bool
 }
@@ -35,18 +37,20 @@ type IRow interface {
 type EachFn func(i int, row IRow) (isBreak bool)
 
 type rows struct {
-	rows    []IRow
+	rows    []*row
 	rowsLen int // 行数据长度， 每次 Append 都将会增加
 }
 
 type row struct {
-	Data  interface{} // 行数据
-	index int         // 行索引
-	errs  Errors      // 错误
-	isErr bool        // 是否存在错误， 设置 errs 的时候将会设置为 true
+	Data    interface{} // 行数据
+	index   int         // 行索引
+	cells   []string    // 原始单元格数据，写入错误文件时使用
+	errs    Errors      // 错误
+	isErr   bool        // 是否存在错误， 设置 errs 的时候将会设置为 true
+	details *rows       // 明细行
 }
 
-func newRows() IRows {
+func newRows() *rows {
 	return &rows{}
 }
 
@@ -67,11 +71,25 @@ func (rs *rows) EachReverse(fn EachFn) {
 }
 
 func (rs *rows) Append(data interface{}, index int) {
-	rs.rows = append(rs.rows, &row{
+	rs.appendRow(data, index, nil)
+}
+
+// 追加数据并保留原始单元格数据
+func (rs *rows) appendRow(data interface{}, index int, cells []string) *row {
+	r := &row{
 		Data:  data,
 		index: index,
-	})
+		cells: cells,
+	}
+	rs.rows = append(rs.rows, r)
 	rs.rowsLen++
+	return r
+}
+
+// 追加另一个 rows 的所有行
+func (rs *rows) appendRows(other *rows) {
+	rs.rows = append(rs.rows, other.rows...)
+	rs.rowsLen += other.rowsLen
 }
 
 func (rs *rows) Count() int {
@@ -135,3 +153,10 @@ func (rs *row) GetFormIndex() (formIndex int) {
 func (rs *row) IsErr() bool {
 	return rs.isErr
 }
+
+func (rs *row) GetDetails() IRows {
+	if rs.details == nil {
+		return newRows() // 返回一个空的，避免外部调用空指针
+	}
+	return rs.details
+}
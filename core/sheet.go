@@ -0,0 +1,172 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SheetSelector 工作表选择器，返回要导入的工作表名称。工作表不存在时返回 *SheetNotFoundError
+type SheetSelector func(file *File) (sheetName string, err error)
+
+// SheetNotFoundError 工作表不存在
+type SheetNotFoundError struct {
+	Sheet     string   // 要选择的工作表，例如 "明细"、index 1、active sheet
+	Available []string // 工作簿中所有的工作表
+}
+
+func (p *SheetNotFoundError) Error() string {
+	return fmt.Sprintf("sheet %s not found, available sheets: %s. ", p.Sheet, strings.Join(p.Available, ", "))
+}
+
+// SheetByIndex 根据索引选择工作表，索引从 0 开始，包含隐藏的工作表
+func SheetByIndex(index int) SheetSelector {
+	return func(file *File) (string, error) {
+		sheetList := file.GetSheetList()
+		if index < 0 || index >= len(sheetList) {
+			return "", &SheetNotFoundError{Sheet: fmt.Sprintf("index %d", index), Available: sheetList}
+		}
+		return sheetList[index], nil
+	}
+}
+
+// SheetByName 根据名称选择工作表
+func SheetByName(name string) SheetSelector {
+	return func(file *File) (string, error) {
+		sheetList := file.GetSheetList()
+		for _, sheetName := range sheetList {
+			if sheetName == name {
+				return sheetName, nil
+			}
+		}
+		return "", &SheetNotFoundError{Sheet: fmt.Sprintf("%q", name), Available: sheetList}
+	}
+}
+
+// SheetActive 选择打开文件时显示的工作表（活动工作表），csv/tsv 只有一个工作表
+func SheetActive() SheetSelector {
+	return func(file *File) (string, error) {
+		if file.isCSV() {
+			return csvSheetName, nil
+		}
+
+		sheetList := file.GetSheetList()
+		index := file.GetActiveSheetIndex()
+		if index < 0 || index >= len(sheetList) {
+			return "", &SheetNotFoundError{Sheet: "active sheet", Available: sheetList}
+		}
+		return sheetList[index], nil
+	}
+}
+
+// SheetFirstVisible 选择第一个可见的工作表，跳过隐藏的工作表，csv/tsv 只有一个工作表
+func SheetFirstVisible() SheetSelector {
+	return func(file *File) (string, error) {
+		if file.isCSV() {
+			return csvSheetName, nil
+		}
+
+		sheetList := file.GetSheetList()
+		for _, sheetName := range sheetList {
+			visible, err := file.GetSheetVisible(sheetName)
+			if err != nil {
+				return "", err
+			}
+			if visible {
+				return sheetName, nil
+			}
+		}
+		return "", &SheetNotFoundError{Sheet: "first visible sheet", Available: sheetList}
+	}
+}
+
+// SheetImplementor 工作表的实现者，每个工作表有自己的中转结构体和提交方法。
+// 实现了 ContextSubmitter 的实现者会调用 SubmitWithContext 代替 Submit，试运行时实现了 RowsValidator 的实现者会调用 Validate 代替 Submit
+type SheetImplementor interface {
+	// TransferStruct 对应工作表的中转结构体
+	TransferStruct() interface{}
+	// Submit 提交数据
+	Submit(rows IRows)
+}
+
+// SheetScheduler 工作表的设置
+type SheetScheduler interface {
+	// SetUniqueColumn 设置唯一列进行行数据聚合
+	SetUniqueColumn(indexes ...int) error
+	// SetErrorWriteBackMode 设置错误会写模式
+	SetErrorWriteBackMode(mode ErrorWriteBackMode)
+	// SetDetailSheet 设置明细工作表，返回明细工作表的设置（明细工作表不支持唯一列，SetUniqueColumn 返回错误）。
+	// masterColumn 为主表的关联列索引，detailColumn 为明细工作表的关联列索引，都从 0 开始。
+	// 明细行随关联的主表行一起提交，主表行没有明细行、明细行没有主表行时都会写入错误文件
+	SetDetailSheet(selector SheetSelector, implementor DetailImplementor, skipRowNum int, masterColumn, detailColumn int) SheetScheduler
+}
+
+// 工作表。每个工作表有自己的头部行、中转结构体、唯一列和实现者，同一个任务中按添加顺序依次读取
+type sheetScheduler struct {
+	task            *taskScheduler   // 所属任务
+	selector        SheetSelector    // 工作表选择器
+	implementor     SheetImplementor // 实现者
+	headerRows      [][]string       // 头部行数据，用于写入错误文件
+	rowsCount       int              // 总行数
+	skipRowNum      int              // 跳过行数。头部行的数量
+	sheetName       string           // 工作表
+	transferStruct  *transferStruct  // 转换结构字段数量
+	maxColumnNum    int              // 列最大列数，取决于最大的字段数量和行数据列数，主要用于写入错误列时追加最到最后一列； 只有两种情况会写入这个值： 1. 初始化时的 transferStruct.Num, 2. 附加列时的最大列
+	groupRows       *groupRows
+	headerFirstData *headerFirstData
+	master          *sheetScheduler // 明细工作表的主表
+	detail          *detailLink     // 主表的明细工作表
+
+	// 运行时的状态
+	batch       *submitBatch       // 等待提交的批次
+	errMessages *sheetErrors       // 工作表的错误消息
+	checkpoint  *checkpointTracker // 检查点记录
+}
+
+func newSheetScheduler(task *taskScheduler, selector SheetSelector, implementor SheetImplementor, skipRowNum int) *sheetScheduler {
+	if skipRowNum <= 0 {
+		skipRowNum = 2
+	}
+	return &sheetScheduler{
+		task:           task,
+		selector:       selector,
+		implementor:    implementor,
+		skipRowNum:     skipRowNum,
+		transferStruct: &transferStruct{},
+		groupRows: &groupRows{
+			keyMapRows:         make(map[string]*uniqueColumn),
+			indexes:            []int{},
+			indexesLen:         0,
+			errorWriteBackMode: ErrorWriteBackModeAnyRow,
+		},
+		headerFirstData: &headerFirstData{},
+	}
+}
+
+// 选择工作表并初始化中转结构体
+func (s *sheetScheduler) open(file *File) (err error) {
+	if s.sheetName, err = s.selector(file); err != nil {
+		return err
+	}
+
+	if s.transferStruct.typeOf == nil {
+		return s.setTransferStruct()
+	}
+	return nil
+}
+
+// 是否存在数据行
+func (s *sheetScheduler) hasDataRows() bool {
+	return s.rowsCount > s.skipRowNum
+}
+
+func (s *sheetScheduler) logInfo(phase, msg string, args ...interface{}) {
+	s.task.logger.Info(msg, s.task.logArgs(s.sheetName, phase, args)...)
+}
+
+func (s *sheetScheduler) logWarn(phase, msg string, args ...interface{}) {
+	s.task.logger.Warn(msg, s.task.logArgs(s.sheetName, phase, args)...)
+}
+
+func (s *sheetScheduler) logError(phase, msg string, args ...interface{}) {
+	s.task.logger.Error(msg, s.task.logArgs(s.sheetName, phase, args)...)
+}
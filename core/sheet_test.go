@@ -0,0 +1,142 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSheetSelector(t *testing.T) {
+	f := newTestFile(t, [][]interface{}{{"说明"}})
+	if err := f.SetSheetName("Sheet1", "说明"); err != nil {
+		t.Fatal(err)
+	}
+	writeTestSheet(t, f, "数据", [][]interface{}{{"A"}})
+	writeTestSheet(t, f, "其它", [][]interface{}{{"A"}})
+	f.SetActiveSheet(2)
+	if err := f.SetSheetVisible("说明", false); err != nil {
+		t.Fatal(err)
+	}
+
+	csv, err := OpenBytesAutoFile([]byte("A,B\n1,2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		file     *File
+		selector SheetSelector
+		want     string
+		notFound bool
+	}{
+		{"by index includes hidden sheets", f, SheetByIndex(0), "说明", false},
+		{"by index", f, SheetByIndex(1), "数据", false},
+		{"index out of range", f, SheetByIndex(3), "", true},
+		{"by name", f, SheetByName("其它"), "其它", false},
+		{"name not found", f, SheetByName("明细"), "", true},
+		{"active", f, SheetActive(), "其它", false},
+		{"first visible", f, SheetFirstVisible(), "数据", false},
+		{"csv active", csv, SheetActive(), csvSheetName, false},
+		{"csv first visible", csv, SheetFirstVisible(), csvSheetName, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.selector(tt.file)
+			var notFound *SheetNotFoundError
+			if tt.notFound {
+				if !errors.As(err, &notFound) || !reflect.DeepEqual(notFound.Available, []string{"说明", "数据", "其它"}) {
+					t.Errorf("got error %v, want sheet not found", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// 明细工作表的中转结构体
+type testDetailRow struct {
+	Key string
+	Qty int64
+}
+
+func TestMultiSheet(t *testing.T) {
+	newFile := func(t *testing.T) *File {
+		f := newTestFile(t, [][]interface{}{{"A", "B", "C"}, {"a", "b", "c"}, {"k1", 1, "x"}, {"k2", -2, "y"}})
+		writeTestSheet(t, f, "明细", [][]interface{}{{"主键", "数量"}, {"k1", 1}, {"k2", "x"}, {"k1", 20}})
+		return f
+	}
+
+	for _, concurrency := range []int{1, 3} {
+		implementor := &testImplementor{file: newFile(t)}
+		detail := &testImplementor{transfer: func() interface{} { return &testDetailRow{} }}
+		task := newTestTask(implementor, 2)
+		task.SetConcurrency(concurrency)
+		if err := task.AddSheet(SheetByName("明细"), detail, 1).SetUniqueColumn(0); err != nil {
+			t.Fatal(err)
+		}
+		if err := task.Run(); err != nil {
+			t.Fatal(err)
+		}
+
+		// 每个工作表使用自己的实现者，唯一列分组一起提交，解析失败的行不提交
+		submitted := implementor.submitted()
+		sort.Ints(submitted)
+		if !reflect.DeepEqual(submitted, []int{2, 3}) {
+			t.Errorf("concurrency %d: submitted got %v, want [2 3]", concurrency, submitted)
+		}
+		if !reflect.DeepEqual(detail.submits, [][]int{{1, 3}}) {
+			t.Errorf("concurrency %d: detail submits got %v, want [[1 3]]", concurrency, detail.submits)
+		}
+		if implementor.doneCount != 5 || implementor.errorCount != 2 {
+			t.Errorf("concurrency %d: done count %d, error count %d, want 5 and 2", concurrency, implementor.doneCount, implementor.errorCount)
+		}
+
+		// 错误文件中每个工作表对应一个错误工作表
+		errFile := implementor.errs.GetErrFile()
+		if got := errFile.GetSheetList(); !reflect.DeepEqual(got, []string{"Sheet1", "明细"}) {
+			t.Errorf("concurrency %d: error sheets got %v", concurrency, got)
+		}
+		want := [][]string{{"A", "B", "C", "错误提示"}, {"a", "b", "c"}, {"k2", "-2", "y", "negative -2"}}
+		if got := readTestFile(t, errFile, "Sheet1"); !reflect.DeepEqual(got, want) {
+			t.Errorf("concurrency %d: got %q, want %q", concurrency, got, want)
+		}
+		want = [][]string{{"主键", "数量", "错误提示"}, {"k2", "x", `数量(B3) "x" 不是有效的整数`}}
+		if got := readTestFile(t, errFile, "明细"); !reflect.DeepEqual(got, want) {
+			t.Errorf("concurrency %d: got %q, want %q", concurrency, got, want)
+		}
+	}
+}
+
+func TestMultiSheetError(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector SheetSelector
+		notFound bool
+	}{
+		{"sheet not found", SheetByName("明细"), true},
+		{"sheet added more than once", SheetByIndex(0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			implementor := &testImplementor{file: newTestFile(t, [][]interface{}{{"A", "B", "C"}, {"a", "b", "c"}, {"k1", 1, "x"}})}
+			task := newTestTask(implementor, 2)
+			task.AddSheet(tt.selector, &testImplementor{}, 1)
+			err := task.Run()
+			var notFound *SheetNotFoundError
+			if err == nil || errors.As(err, &notFound) != tt.notFound {
+				t.Errorf("got error %v", err)
+			}
+			if len(implementor.submitted()) != 0 || implementor.ended {
+				t.Error("no rows should be submitted")
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package core
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/nuominmin/import-kit/model"
+)
+
+// 提交批次。多个分组合并为一个 IRows 提交，错误回写模式仍然按分组生效
+type submitBatch struct {
+	sheet      *sheetScheduler // 所属工作表，不同工作表的行不会合并到同一个批次
+	seq        int             // 批次序号，并发提交时按序号收集结果
+	groups     []*rows         // 分组，未开启唯一列时每个分组只有一行
+	rowsData   *rows           // 合并后提交的行数据
+	skipSubmit bool            // 不提交，只收集错误。例如单元格解析失败的分组
+	cancelled  bool            // 上下文被取消，没有提交，也不收集
+}
+
+func newSubmitBatch(sheet *sheetScheduler) *submitBatch {
+	return &submitBatch{sheet: sheet, rowsData: newRows()}
+}
+
+// 追加分组
+func (b *submitBatch) append(group *rows) {
+	b.groups = append(b.groups, group)
+	b.rowsData.appendRows(group)
+}
+
+// 并发提交的工作池。
+// 批次由多个 worker 并发提交，收集者按批次序号依次收集结果，错误消息和完成行数只会在收集者中修改
+type submitPool struct {
+	jobs      chan *submitBatch
+	results   chan *submitBatch
+	workers   sync.WaitGroup
+	collected chan struct{} // 收集者退出时关闭
+	seq       int           // 下一个批次的序号
+}
+
+// 启动工作池，并发数小于等于 1 时逐个批次同步提交
+func (p *taskScheduler) startPool() {
+	if p.concurrency <= 1 {
+		return
+	}
+
+	pool := &submitPool{
+		jobs:      make(chan *submitBatch, p.concurrency),
+		results:   make(chan *submitBatch, p.concurrency),
+		collected: make(chan struct{}),
+	}
+
+	for i := 0; i < p.concurrency; i++ {
+		pool.workers.Add(1)
+		go func() {
+			defer pool.workers.Done()
+			for batch := range pool.jobs {
+				p.process(batch)
+				pool.results <- batch
+			}
+		}()
+	}
+
+	go func() {
+		defer close(pool.collected)
+		pending := make(map[int]*submitBatch) // 提前完成的批次，等待前面的批次收集后再收集
+		next := 0
+		for batch := range pool.results {
+			pending[batch.seq] = batch
+			for {
+				batch, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				p.collectBatch(batch)
+				next++
+			}
+		}
+	}()
+
+	p.pool = pool
+}
+
+// 停止工作池，等待所有批次提交和收集完成
+func (p *taskScheduler) stopPool() {
+	if p.pool == nil {
+		return
+	}
+
+	close(p.pool.jobs)
+	p.pool.workers.Wait()
+	close(p.pool.results)
+	<-p.pool.collected
+	p.pool = nil
+}
+
+// 分发分组到批次，批次的行数达到批量大小时提交
+func (s *sheetScheduler) dispatch(group *rows) {
+	// 存在单元格解析或校验失败的行时，默认整组不提交，直接写入错误文件
+	if !s.task.submitInvalid && group.IsErr() {
+		batch := newSubmitBatch(s)
+		batch.append(group)
+		batch.skipSubmit = true
+		s.task.submit(batch)
+		return
+	}
+
+	s.batch.append(group)
+	if s.batch.rowsData.Count() >= s.task.batchSize {
+		s.flush()
+	}
+}
+
+// 提交等待中的批次
+func (s *sheetScheduler) flush() {
+	if s.batch.rowsData.Count() == 0 {
+		return
+	}
+
+	batch := s.batch
+	s.batch = newSubmitBatch(s)
+	s.task.submit(batch)
+}
+
+// 提交批次，开启并发时交给工作池
+func (p *taskScheduler) submit(batch *submitBatch) {
+	if p.pool == nil {
+		p.process(batch)
+		p.collectBatch(batch)
+		return
+	}
+
+	batch.seq = p.pool.seq
+	p.pool.seq++
+	p.pool.jobs <- batch
+}
+
+// 提交批次中的数据
+func (p *taskScheduler) process(batch *submitBatch) {
+	// 上下文被取消后不再提交，包括已经分发到工作池中的批次
+	if p.ctx.Err() != nil {
+		batch.cancelled = true
+		return
+	}
+
+	if batch.skipSubmit {
+		return
+	}
+
+	// Submit 或 Validate 发生 panic 时，批次中的所有行都设置为错误
+	defer func() {
+		if r := recover(); r != nil {
+			err := fmt.Errorf("submit panic: %v", r)
+			if p.dryRun {
+				err = fmt.Errorf("validate panic: %v", r)
+			}
+			batch.sheet.logError(logPhaseSubmit, err.Error(), "error", err, "row_index", batch.rowsData.GetFirstRow().GetFormIndex(), "stack", string(debug.Stack()))
+			batch.rowsData.SetRowsErrs(err)
+		}
+	}()
+
+	// 试运行时不提交，只调用实现者的校验
+	if p.dryRun {
+		if validator, ok := batch.sheet.implementor.(RowsValidator); ok {
+			validator.Validate(p.ctx, batch.rowsData)
+		}
+		return
+	}
+
+	/*
+		场景：
+			1. 逐行提交	-> SubmitForEach -> error SetRowErr
+			2. 一次性提交	-> Submit -> error SetRowErr
+	*/
+
+	// 提交数据
+	submitTime := time.Now()
+	if submitter, ok := batch.sheet.implementor.(ContextSubmitter); ok {
+		submitter.SubmitWithContext(p.ctx, batch.rowsData)
+	} else {
+		batch.sheet.implementor.Submit(batch.rowsData)
+	}
+	p.metrics.RowsSubmitted(batch.rowsData.Count(), time.Since(submitTime))
+}
+
+// 收集批次中每个分组的错误
+func (p *taskScheduler) collectBatch(batch *submitBatch) {
+	if batch.cancelled {
+		return
+	}
+
+	for _, group := range batch.groups {
+		batch.sheet.collect(group)
+	}
+}
+
+// 从已提交的分组中尝试获取错误消息并写入到 errs，只有错误行的原始数据会被保留
+func (s *sheetScheduler) collect(group *rows) {
+	s.collectRows(group, group.IsErr())
+
+	// 明细行随主表行完成，主表行或明细行存在错误时明细行按明细工作表的错误回写模式写入错误文件
+	if s.detail != nil {
+		for _, rowData := range group.rows {
+			if rowData.details != nil {
+				s.detail.sheet.collectRows(rowData.details, group.IsErr() || rowData.details.IsErr())
+			}
+		}
+	}
+
+	s.task.progress()
+}
+
+// 收集行的错误，isErr 为 true 时任意行模式会写入所有行
+func (s *sheetScheduler) collectRows(group *rows, isErr bool) {
+	var checkpointErrors []model.CheckpointError
+	if isErr {
+		failedNum := 0
+		for j := 0; j < group.Count(); j++ {
+			rowData := group.rows[j]
+			formIndex := rowData.GetFormIndex()
+			var printErr error
+			var cellMessages map[int]string
+			var items []ErrorReportItem
+			if errs := rowData.GetErrs(); len(errs) > 0 {
+				printErr = errs.PrintError()
+				cellMessages = errs.cellMessages()
+				items = errs.reportItems()
+				failedNum++
+				s.logWarn(logPhaseSubmit, "row error", "error", printErr, "row_index", formIndex)
+			}
+			if s.groupRows.errorWriteBackMode.errorWriteBackModeIsAny() || printErr != nil {
+				s.errMessages.Append(formIndex, rowData.cells, printErr, cellMessages, items)
+				if s.checkpoint != nil {
+					checkpointErrors = append(checkpointErrors, newCheckpointError(formIndex, rowData.cells, printErr, cellMessages, items))
+				}
+			}
+		}
+		s.task.metrics.RowsFailed(failedNum)
+	}
+
+	if s.checkpoint != nil {
+		s.checkpoint.finish(group, checkpointErrors)
+	}
+
+	s.task.doneCount += group.Count() // 完成行数
+}
+
+// 进度更新，完成行数每跨过一个完成间隔回调一次，同时保存检查点
+func (p *taskScheduler) progress() {
+	if p.doneCount/p.progressInterval <= p.progressDoneCount/p.progressInterval {
+		return
+	}
+
+	p.progressDoneCount = p.doneCount
+	p.saveCheckpoint()
+	if p.progressFn == nil {
+		return
+	}
+
+	// 进度更新发生 panic 时不影响任务运行
+	defer func() {
+		if r := recover(); r != nil {
+			p.logError(logPhaseProgress, "progress panic", "error", r, "stack", string(debug.Stack()))
+		}
+	}()
+
+	if err := p.progressFn(p.rowsCount, p.doneCount); err != nil {
+		p.logWarn(logPhaseProgress, "heart beat error", "error", err)
+	}
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSubmitBatch(t *testing.T) {
+	// 30 行数据，唯一列 A 的分组交错出现，第 10 行的数量为负数
+	const rowNum = 30
+	data := [][]interface{}{{"A", "B", "C"}}
+	keys := make(map[int]string, rowNum)
+	groupSizes := make(map[string]int)
+	for i := 1; i <= rowNum; i++ {
+		b := i
+		if i == 10 {
+			b = -i
+		}
+		keys[i] = fmt.Sprintf("k%d", i%4)
+		groupSizes[keys[i]]++
+		data = append(data, []interface{}{keys[i], b, "x"})
+	}
+
+	tests := []struct {
+		name        string
+		batchSize   int
+		concurrency int
+		unique      bool
+	}{
+		{"row by row", 1, 1, false},
+		{"batch", 7, 1, false},
+		{"batch and concurrency", 7, 4, false},
+		{"unique column", 1, 1, true},
+		{"unique column batch", 10, 1, true},
+		{"unique column batch and concurrency", 10, 4, true},
+		{"concurrency", 1, 8, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			implementor := &testImplementor{file: newTestFile(t, data)}
+			// 先提交的批次更慢，并发时后面的批次先完成
+			implementor.check = func(row IRow) error {
+				time.Sleep(time.Duration(rowNum-row.GetFormIndex()) * 50 * time.Microsecond)
+				if row.GetData().(*testRow).B < 0 {
+					return fmt.Errorf("negative %d", row.GetData().(*testRow).B)
+				}
+				return nil
+			}
+			task := newTestTask(implementor, 1)
+			task.SetBatchSize(tt.batchSize)
+			task.SetConcurrency(tt.concurrency)
+			if tt.unique {
+				if err := task.SetUniqueColumn(0); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := task.Run(); err != nil {
+				t.Fatal(err)
+			}
+
+			// 唯一列的分组在最后一行读取后分发，分组内按读取顺序
+			var want []int
+			if tt.unique {
+				for _, last := range []int{27, 28, 29, 30} {
+					for i := 1; i <= rowNum; i++ {
+						if keys[i] == keys[last] {
+							want = append(want, i)
+						}
+					}
+				}
+			} else {
+				for i := 1; i <= rowNum; i++ {
+					want = append(want, i)
+				}
+			}
+
+			got := implementor.submitted()
+			if tt.concurrency > 1 {
+				got = append([]int(nil), got...)
+				sort.Ints(got)
+				want = append([]int(nil), want...)
+				sort.Ints(want)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("submitted got %v, want %v", got, want)
+			}
+
+			for _, submit := range implementor.submits {
+				// 每次提交的行数达到批量大小，最后一次可以更少，分组不会拆分到多次提交
+				if !tt.unique && len(submit) > tt.batchSize {
+					t.Errorf("submit %v exceeds batch size %d", submit, tt.batchSize)
+				}
+				counts := make(map[string]int)
+				for _, index := range submit {
+					counts[keys[index]]++
+				}
+				for key, count := range counts {
+					if tt.unique && count != groupSizes[key] {
+						t.Errorf("group %s is split in submit %v", key, submit)
+					}
+				}
+			}
+
+			// 任意行模式下分组中的所有行都写入错误文件，错误按行号排序
+			wantErrors := [][]string{{"A", "B", "C", "错误提示"}}
+			for i := 1; i <= rowNum; i++ {
+				if i == 10 {
+					wantErrors = append(wantErrors, []string{"k2", "-10", "x", "negative -10"})
+				} else if tt.unique && keys[i] == keys[10] {
+					wantErrors = append(wantErrors, []string{"k2", strconv.Itoa(i), "x"})
+				}
+			}
+			if implementor.doneCount != rowNum || implementor.errorCount != len(wantErrors)-1 {
+				t.Errorf("done count %d, error count %d, want %d and %d", implementor.doneCount, implementor.errorCount, rowNum, len(wantErrors)-1)
+			}
+			if got := readTestFile(t, implementor.errs.GetErrFile(), "Sheet1"); !reflect.DeepEqual(got, wantErrors) {
+				t.Errorf("error file got %q, want %q", got, wantErrors)
+			}
+		})
+	}
+}
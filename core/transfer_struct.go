@@ -0,0 +1,143 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// 中转结构体字段的 tag 名称。
+//
+//	`importkit:"header=商品编码"` 根据表头名称映射列
+//	`importkit:"col=C"`          根据列名映射列
+//	`importkit:"index=3"`        根据列索引映射列，从 0 开始
+//	`importkit:"-"`              忽略该字段
+//	`importkit:"layout=2006-01-02|2006/01/02"` time.Time 字段的日期格式，多个格式使用 '|' 分隔，必须是最后一个选项
+//
+// 多个选项使用 ',' 分隔，例如 `importkit:"header=下单日期,layout=2006-01-02"`。
+// 未设置 tag 的字段按照字段顺序映射列，被忽略的字段和未导出的字段不占用位置
+const transferStructTagName = "importkit"
+
+type transferStruct struct {
+	fieldNum int // 字段数量，不包含被忽略的字段
+	typeOf   reflect.Type
+	fields   []*transferField // 需要解析的字段
+}
+
+// 中转结构体字段
+type transferField struct {
+	index    int             // 结构体字段索引
+	name     string          // 字段名称
+	typeOf   reflect.Type    // 字段类型
+	header   string          // tag 指定的表头名称，每次运行时根据表头解析出列索引
+	column   int             // 列索引
+	layouts  []string        // time.Time 字段的日期格式
+	required bool            // 是否必填
+	rules    []*validateRule // 校验规则
+}
+
+// 解析中转结构体的字段和 tag
+func newTransferStruct(typeOf reflect.Type) (*transferStruct, error) {
+	p := &transferStruct{typeOf: typeOf}
+	position := 0 // 按位置映射的列索引
+	for i := 0; i < typeOf.NumField(); i++ {
+		structField := typeOf.Field(i)
+		tag, hasTag := structField.Tag.Lookup(transferStructTagName)
+		if !structField.IsExported() || tag == "-" {
+			continue
+		}
+
+		field := &transferField{
+			index:  i,
+			name:   structField.Name,
+			typeOf: structField.Type,
+			column: position,
+		}
+		position++
+
+		if hasTag {
+			if err := field.parseTag(tag); err != nil {
+				return nil, err
+			}
+		}
+		if validateTag, ok := structField.Tag.Lookup(validateTagName); ok {
+			var err error
+			if field.required, field.rules, err = parseValidateTag(field.name, validateTag); err != nil {
+				return nil, err
+			}
+		}
+		p.fields = append(p.fields, field)
+	}
+	p.fieldNum = len(p.fields)
+	return p, nil
+}
+
+// 解析 tag，多个选项使用 ',' 分隔
+func (p *transferField) parseTag(tag string) error {
+	options := strings.Split(tag, ",")
+	for i, option := range options {
+		key, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+		value = strings.TrimSpace(value)
+		switch key {
+		case "":
+		case "layout": // 日期格式可能包含 ','，取剩余的全部内容
+			value, _ = strings.CutPrefix(strings.TrimSpace(strings.Join(options[i:], ",")), "layout=")
+			p.layouts = strings.Split(value, "|")
+			return nil
+		case "header":
+			p.header = value
+		case "col":
+			column, err := excelize.ColumnNameToNumber(value)
+			if err != nil {
+				return fmt.Errorf("field %s tag col error: %s", p.name, err.Error())
+			}
+			p.column = column - 1
+		case "index":
+			column, err := strconv.Atoi(value)
+			if err != nil || column < 0 {
+				return fmt.Errorf("field %s tag index error: invalid index %q", p.name, value)
+			}
+			p.column = column
+		default:
+			return fmt.Errorf("field %s tag error: unknown option %q", p.name, key)
+		}
+	}
+	return nil
+}
+
+// 根据表头解析 header tag 对应的列索引，返回映射到的最大列数
+func (p *transferStruct) resolveColumns(headerData []string) (columnNum int, err error) {
+	mapHeaderIndex := make(map[string]int, len(headerData))
+	for i := len(headerData) - 1; i >= 0; i-- { // 表头重复时取第一个
+		mapHeaderIndex[strings.TrimSpace(headerData[i])] = i
+	}
+
+	columnNum = p.fieldNum
+	for _, field := range p.fields {
+		if field.header != "" {
+			column, ok := mapHeaderIndex[field.header]
+			if !ok {
+				return 0, fmt.Errorf("field %s header %q not found", field.name, field.header)
+			}
+			field.column = column
+		}
+		if field.column+1 > columnNum {
+			columnNum = field.column + 1
+		}
+	}
+	return columnNum, nil
+}
+
+// 不需要解析表头即可确定的最大列数
+func (p *transferStruct) staticColumnNum() int {
+	columnNum := p.fieldNum
+	for _, field := range p.fields {
+		if field.header == "" && field.column+1 > columnNum {
+			columnNum = field.column + 1
+		}
+	}
+	return columnNum
+}
@@ -0,0 +1,144 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTransferStructColumns(t *testing.T) {
+	type positional struct {
+		A string
+		B string
+		C string
+	}
+	type ignored struct {
+		A string
+		B string `importkit:"-"`
+		c string
+		D string
+	}
+	type tagged struct {
+		Name  string `importkit:"header=名称"`
+		Code  string `importkit:"col=D"`
+		Qty   int64  `importkit:"index=5"`
+		Note  string
+		Skip  string `importkit:"-"`
+		Price string `importkit:"header=单价"`
+	}
+	type layout struct {
+		Date string `importkit:"col=B,layout=2006-01-02|2006/01/02"`
+	}
+
+	header := []string{"编码", " 名称 ", "单价", "名称", "备注", "数量"}
+	tests := []struct {
+		name      string
+		value     interface{}
+		columns   map[string]int
+		columnNum int
+	}{
+		{"by position", positional{}, map[string]int{"A": 0, "B": 1, "C": 2}, 3},
+		{"ignored fields take no position", ignored{c: ""}, map[string]int{"A": 0, "D": 1}, 2},
+		{"header, col and index", tagged{}, map[string]int{"Name": 1, "Code": 3, "Qty": 5, "Note": 3, "Price": 2}, 6},
+		{"col with layout", layout{}, map[string]int{"Date": 1}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, err := newTransferStruct(reflect.TypeOf(tt.value))
+			if err != nil {
+				t.Fatal(err)
+			}
+			columnNum, err := ts.resolveColumns(header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if columnNum != tt.columnNum {
+				t.Errorf("column num got %d, want %d", columnNum, tt.columnNum)
+			}
+			got := make(map[string]int, len(ts.fields))
+			for _, field := range ts.fields {
+				got[field.name] = field.column
+			}
+			if !reflect.DeepEqual(got, tt.columns) {
+				t.Errorf("columns got %v, want %v", got, tt.columns)
+			}
+		})
+	}
+}
+
+func TestTransferStructTagError(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{"invalid col", struct {
+			A string `importkit:"col=1"`
+		}{}},
+		{"negative index", struct {
+			A string `importkit:"index=-1"`
+		}{}},
+		{"invalid index", struct {
+			A string `importkit:"index=x"`
+		}{}},
+		{"unknown option", struct {
+			A string `importkit:"name=x"`
+		}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newTransferStruct(reflect.TypeOf(tt.value)); err == nil {
+				t.Error("want an error")
+			}
+		})
+	}
+}
+
+func TestTransferStructHeaderNotFound(t *testing.T) {
+	ts, err := newTransferStruct(reflect.TypeOf(struct {
+		A string `importkit:"header=不存在"`
+	}{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ts.resolveColumns([]string{"A", "B"}); err == nil {
+		t.Error("want an error")
+	}
+}
+
+func TestTransferStructRun(t *testing.T) {
+	type content struct {
+		Name   string
+		Helper string `importkit:"-"`
+		Qty    int64  `importkit:"header=数量"`
+		Note   string `importkit:"col=D"`
+	}
+
+	// 数量 列按表头查找，表头移动后仍然映射到正确的列
+	tests := []struct {
+		name   string
+		header []interface{}
+		row    []interface{}
+	}{
+		{"header in order", []interface{}{"名称", "x", "数量", "备注"}, []interface{}{"k1", "x1", 3, "r1"}},
+		{"header moved", []interface{}{"名称", "数量", "x", "备注"}, []interface{}{"k1", 3, "x1", "r1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []content
+			implementor := &testImplementor{
+				file:     newTestFile(t, [][]interface{}{tt.header, tt.row}),
+				transfer: func() interface{} { return &content{} },
+				check: func(row IRow) error {
+					got = append(got, *row.GetData().(*content))
+					return nil
+				},
+			}
+			if err := newTestTask(implementor, 1).Run(); err != nil {
+				t.Fatal(err)
+			}
+			want := []content{{Name: "k1", Qty: 3, Note: "r1"}}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 中转结构体字段的校验 tag 名称，在单元格解析成功后、Submit 之前校验。
+//
+//	required       不能为空
+//	min=1 max=64   字符串校验长度，数字校验大小
+//	len=11         字符串长度
+//	oneof=A|B|C    只能是其中之一
+//	regex=^\\d{11}$ 正则表达式，正则可能包含 ','，必须是最后一个规则
+//
+// 多个规则使用 ',' 分隔，例如 `validate:"required,max=64,regex=^\\d{11}$"`，
+// struct tag 的值是 go 的字符串字面量，正则中的反斜杠需要转义。
+// 非必填的空单元格不会校验其他规则
+const validateTagName = "validate"
+
+// ValidateError 校验错误
+type ValidateError struct {
+	Rule  string // 校验规则，例如 required、max
+	Param string // 规则参数
+	msg   string
+}
+
+func (p *ValidateError) Error() string {
+	return p.msg
+}
+
+// 校验规则
+type validateRule struct {
+	name   string
+	param  string
+	num    float64        // min、max、len 的数值参数
+	values []string       // oneof 的参数
+	regexp *regexp.Regexp // regex 的参数
+}
+
+// 解析校验 tag
+func parseValidateTag(fieldName, tag string) (required bool, rules []*validateRule, err error) {
+	options := strings.Split(tag, ",")
+	for i, option := range options {
+		name, param, _ := strings.Cut(strings.TrimSpace(option), "=")
+		rule := &validateRule{name: name, param: param}
+		switch name {
+		case "":
+			continue
+		case "required":
+			required = true
+			continue
+		case "min", "max", "len":
+			if rule.num, err = strconv.ParseFloat(param, 64); err != nil {
+				return false, nil, fmt.Errorf("field %s validate %s error: invalid param %q", fieldName, name, param)
+			}
+		case "oneof":
+			rule.values = strings.Split(param, "|")
+		case "regex": // 正则可能包含 ','，取剩余的全部内容
+			rule.param, _ = strings.CutPrefix(strings.TrimSpace(strings.Join(options[i:], ",")), "regex=")
+			if rule.regexp, err = regexp.Compile(rule.param); err != nil {
+				return false, nil, fmt.Errorf("field %s validate regex error: %s", fieldName, err.Error())
+			}
+			return required, append(rules, rule), nil
+		default:
+			return false, nil, fmt.Errorf("field %s validate error: unknown rule %q", fieldName, name)
+		}
+		rules = append(rules, rule)
+	}
+	return required, rules, nil
+}
+
+// 校验字段，data 为单元格数据
+func (p *transferField) validate(fieldValue reflect.Value, data string, l localizer) error {
+	if data == "" {
+		if p.required {
+			return newValidateError(l, "required", "")
+		}
+		return nil
+	}
+
+	for fieldValue.Kind() == reflect.Ptr && !fieldValue.IsNil() {
+		fieldValue = fieldValue.Elem()
+	}
+
+	for _, rule := range p.rules {
+		if err := rule.validate(fieldValue, data, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *validateRule) validate(fieldValue reflect.Value, data string, l localizer) error {
+	switch p.name {
+	case "oneof":
+		for _, value := range p.values {
+			if value == data {
+				return nil
+			}
+		}
+		return newValidateError(l, p.name, strings.Join(p.values, "/"))
+	case "regex":
+		if !p.regexp.MatchString(data) {
+			return newValidateError(l, p.name, p.param)
+		}
+		return nil
+	}
+
+	// min、max、len：数字校验大小，其他类型校验单元格数据的长度
+	var num float64
+	var isNum bool
+	switch fieldValue.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		num, isNum = float64(fieldValue.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		num, isNum = float64(fieldValue.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		num, isNum = fieldValue.Float(), true
+	}
+
+	name := p.name
+	if !isNum || p.name == "len" {
+		num = float64(utf8.RuneCountInString(data))
+		if p.name != "len" {
+			name += "_len"
+		}
+	}
+
+	switch {
+	case p.name == "min" && num < p.num,
+		p.name == "max" && num > p.num,
+		p.name == "len" && num != p.num:
+		return newValidateError(l, name, p.param)
+	}
+	return nil
+}
+
+// 校验错误，消息的 key 为 "validate." + 校验规则
+func newValidateError(l localizer, rule, param string) *ValidateError {
+	return &ValidateError{Rule: rule, Param: param, msg: l.sprintf("validate."+rule, param)}
+}
@@ -0,0 +1,141 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseValidateTagError(t *testing.T) {
+	tests := []struct {
+		name string
+		tag  string
+	}{
+		{"invalid number", "max=x"},
+		{"invalid regex", "regex=["},
+		{"unknown rule", "email"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := parseValidateTag("A", tt.tag); err == nil {
+				t.Error("want an error")
+			}
+		})
+	}
+
+	required, rules, err := parseValidateTag("A", "required,max=64,regex=^\\d{1,3}$")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !required || len(rules) != 2 || rules[1].param != `^\d{1,3}$` {
+		t.Errorf("got required %v and %d rules, want required and 2 rules with a regex containing ','", required, len(rules))
+	}
+}
+
+// 校验失败的单元格错误
+type testValidateError struct {
+	Cell  string
+	Rule  string
+	Param string
+	Msg   string
+}
+
+func TestValidate(t *testing.T) {
+	type content struct {
+		Name  string `validate:"required,max=4"`
+		Phone string `validate:"len=3,regex=^1\\d+$"`
+		Qty   int64  `validate:"min=1,max=10"`
+		Level string `validate:"oneof=A|B|C"`
+	}
+
+	tests := []struct {
+		name      string
+		row       []interface{}
+		locale    Locale
+		want      []testValidateError
+		wantError string
+	}{
+		{name: "valid", row: []interface{}{"苹果", "123", 5, "A"}},
+		{name: "empty optional", row: []interface{}{"苹果", "", "", ""}},
+		{
+			name:      "required",
+			row:       []interface{}{"", "123", 5, "A"},
+			want:      []testValidateError{{"A3", "required", "", "不能为空"}},
+			wantError: "名称(A3) 不能为空",
+		},
+		{
+			name: "length is counted in characters",
+			row:  []interface{}{"红富士苹果", "1234", 5, "A"},
+			want: []testValidateError{
+				{"A3", "max_len", "4", "长度不能大于 4"},
+				{"B3", "len", "3", "长度必须为 3"},
+			},
+			wantError: `名称(A3) "红富士苹果" 长度不能大于 4; 电话(B3) "1234" 长度必须为 3`,
+		},
+		{
+			name: "number range, oneof and regex",
+			row:  []interface{}{"苹果", "234", 11, "D"},
+			want: []testValidateError{
+				{"B3", "regex", `^1\d+$`, "格式不正确"},
+				{"C3", "max", "10", "不能大于 10"},
+				{"D3", "oneof", "A/B/C", "只能是 A/B/C 其中之一"},
+			},
+			wantError: `电话(B3) "234" 格式不正确; 数量(C3) "11" 不能大于 10; 等级(D3) "D" 只能是 A/B/C 其中之一`,
+		},
+		{
+			name:      "en-US",
+			row:       []interface{}{"苹果", "123", 0, "A"},
+			locale:    LocaleEnUS,
+			want:      []testValidateError{{"C3", "min", "1", "must not be less than 1"}},
+			wantError: `数量(C3) "0" must not be less than 1`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []testValidateError
+			implementor := &testImplementor{
+				file: newTestFile(t, [][]interface{}{
+					{"名称", "电话", "数量", "等级"},
+					{"说明", "说明", "说明", "说明"},
+					tt.row,
+				}),
+				transfer: func() interface{} { return &content{} },
+				check: func(row IRow) error {
+					for _, err := range row.GetErrs() {
+						var cellErr *CellError
+						var validateErr *ValidateError
+						if !errors.As(err, &cellErr) || !errors.As(err, &validateErr) {
+							t.Errorf("got error %T, want a cell error of validation", err)
+							continue
+						}
+						got = append(got, testValidateError{cellErr.Cell, validateErr.Rule, validateErr.Param, validateErr.Error()})
+					}
+					return nil
+				},
+			}
+			task := newTestTask(implementor, 2)
+			task.SetSubmitInvalidRows(true)
+			if tt.locale != "" {
+				task.SetLocale(tt.locale)
+			}
+			if err := task.Run(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+
+			if tt.wantError == "" {
+				if implementor.errorCount != 0 {
+					t.Errorf("error count got %d, want 0", implementor.errorCount)
+				}
+				return
+			}
+			// 错误文件中该行的所有校验错误使用 "; " 连接
+			rows := readTestFile(t, implementor.errs.GetErrFile(), "Sheet1")
+			if len(rows) != 3 || rows[2][4] != tt.wantError {
+				t.Errorf("error file got %q, want error %q", rows, tt.wantError)
+			}
+		})
+	}
+}
@@ -29,3 +29,37 @@ type Container interface {
 	// TaskFailed 任务失败
 	TaskFailed(ctx context.Context, taskId, errFileId uint64) (err error)
 }
+
+// TaskCanceller 任务取消，Container 实现该接口时任务被取消后更新取消状态，没有实现时调用 TaskFailed
+type TaskCanceller interface {
+	// TaskCancelled 任务被取消，errFileId 为已处理行的错误文件，没有错误时为 0
+	TaskCancelled(ctx context.Context, taskId, errFileId uint64) (err error)
+}
+
+// TaskValidator 任务试运行，Container 实现该接口时试运行完成后上传错误文件并更新试运行完成状态，
+// 没有实现时没有错误行调用 TaskSucceed，否则上传错误文件并调用 TaskFailed
+type TaskValidator interface {
+	// TaskValidated 任务试运行完成，等待确认导入，errFileId 为校验失败行的错误文件，没有错误时为 0
+	TaskValidated(ctx context.Context, taskId, errFileId uint64) (err error)
+}
+
+// CheckpointStore 检查点存储，Container 实现该接口时导入任务支持断点续传：
+// 运行中定期保存检查点，同一个任务重新运行时从检查点继续，任务完成后删除检查点
+type CheckpointStore interface {
+	// GetCheckpoint 获取检查点，不存在时返回 nil
+	GetCheckpoint(ctx context.Context, taskId uint64) (checkpoint *model.Checkpoint, err error)
+
+	// SaveCheckpoint 保存检查点。每个工作表的 Errors 只包含上次保存之后新增的错误，需要追加到已保存的错误中，
+	// GetCheckpoint 返回所有保存的错误；其它字段直接覆盖
+	SaveCheckpoint(ctx context.Context, taskId uint64, checkpoint *model.Checkpoint) (err error)
+
+	// DeleteCheckpoint 删除检查点
+	DeleteCheckpoint(ctx context.Context, taskId uint64) (err error)
+}
+
+// ErrorReportUploader 错误报告上传，Container 实现该接口时任务结束后存在错误行时上传错误报告，
+// 前端可以按错误码统计和展示失败原因
+type ErrorReportUploader interface {
+	// UploadErrorReport 上传错误报告，report 为 JSON 格式的 core.ErrorReport
+	UploadErrorReport(ctx context.Context, taskId uint64, report []byte) (err error)
+}
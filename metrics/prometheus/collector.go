@@ -0,0 +1,131 @@
+// Package prometheus 导入任务指标的 prometheus 实现
+package prometheus
+
+import (
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nuominmin/import-kit/core"
+)
+
+// Collector 实现了 core.Metrics 和 prometheus.Collector，
+// 可以注册到任意 prometheus.Registerer，例如 prometheus.NewRegistry() 创建的本地注册表
+type Collector struct {
+	rowsRead             prom.Counter
+	rowsSubmitted        prom.Counter
+	rowsFailed           prom.Counter
+	submitDuration       prom.Histogram
+	fileOpenDuration     prom.Histogram
+	errFileBuildDuration prom.Histogram
+	tasks                *prom.CounterVec
+	taskDuration         *prom.HistogramVec
+}
+
+var (
+	_ core.Metrics   = (*Collector)(nil)
+	_ prom.Collector = (*Collector)(nil)
+)
+
+// NewCollector 一个新的指标收集器，namespace 为指标名称的前缀，为空时使用 importkit
+func NewCollector(namespace string) *Collector {
+	if namespace == "" {
+		namespace = "importkit"
+	}
+
+	return &Collector{
+		rowsRead: prom.NewCounter(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "rows_read_total",
+			Help:      "Number of data rows read from import files, excluding header and empty rows.",
+		}),
+		rowsSubmitted: prom.NewCounter(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "rows_submitted_total",
+			Help:      "Number of rows passed to Submit.",
+		}),
+		rowsFailed: prom.NewCounter(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "rows_failed_total",
+			Help:      "Number of rows written to the error file with an error.",
+		}),
+		submitDuration: prom.NewHistogram(prom.HistogramOpts{
+			Namespace: namespace,
+			Name:      "submit_duration_seconds",
+			Help:      "Latency of a single Submit call.",
+			Buckets:   prom.DefBuckets,
+		}),
+		fileOpenDuration: prom.NewHistogram(prom.HistogramOpts{
+			Namespace: namespace,
+			Name:      "file_open_duration_seconds",
+			Help:      "Time spent downloading and opening import files.",
+			Buckets:   prom.DefBuckets,
+		}),
+		errFileBuildDuration: prom.NewHistogram(prom.HistogramOpts{
+			Namespace: namespace,
+			Name:      "error_file_build_duration_seconds",
+			Help:      "Time spent building error files.",
+			Buckets:   prom.DefBuckets,
+		}),
+		tasks: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "tasks_total",
+			Help:      "Number of import tasks by end status.",
+		}, []string{"status"}),
+		taskDuration: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: namespace,
+			Name:      "task_duration_seconds",
+			Help:      "Duration of import tasks by end status.",
+			Buckets:   prom.ExponentialBuckets(1, 2, 14), // 1s ~ 2.3h
+		}, []string{"status"}),
+	}
+}
+
+// 所有指标
+func (c *Collector) collectors() []prom.Collector {
+	return []prom.Collector{
+		c.rowsRead, c.rowsSubmitted, c.rowsFailed,
+		c.submitDuration, c.fileOpenDuration, c.errFileBuildDuration,
+		c.tasks, c.taskDuration,
+	}
+}
+
+// Describe 实现 prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prom.Desc) {
+	for _, collector := range c.collectors() {
+		collector.Describe(ch)
+	}
+}
+
+// Collect 实现 prometheus.Collector
+func (c *Collector) Collect(ch chan<- prom.Metric) {
+	for _, collector := range c.collectors() {
+		collector.Collect(ch)
+	}
+}
+
+func (c *Collector) FileOpened(duration time.Duration) {
+	c.fileOpenDuration.Observe(duration.Seconds())
+}
+
+func (c *Collector) RowsRead(n int) {
+	c.rowsRead.Add(float64(n))
+}
+
+func (c *Collector) RowsSubmitted(n int, duration time.Duration) {
+	c.rowsSubmitted.Add(float64(n))
+	c.submitDuration.Observe(duration.Seconds())
+}
+
+func (c *Collector) RowsFailed(n int) {
+	c.rowsFailed.Add(float64(n))
+}
+
+func (c *Collector) ErrorFileBuilt(duration time.Duration) {
+	c.errFileBuildDuration.Observe(duration.Seconds())
+}
+
+func (c *Collector) TaskEnded(status core.TaskStatus, duration time.Duration) {
+	c.tasks.WithLabelValues(string(status)).Inc()
+	c.taskDuration.WithLabelValues(string(status)).Observe(duration.Seconds())
+}
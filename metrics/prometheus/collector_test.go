@@ -0,0 +1,115 @@
+package prometheus
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/nuominmin/import-kit/core"
+)
+
+func TestCollector(t *testing.T) {
+	collector := NewCollector("")
+	registry := prom.NewRegistry()
+	if err := registry.Register(collector); err != nil {
+		t.Fatalf("register collector error: %v", err)
+	}
+
+	collector.FileOpened(time.Second)
+	collector.RowsRead(10)
+	collector.RowsRead(5)
+	collector.RowsSubmitted(8, 100*time.Millisecond)
+	collector.RowsSubmitted(4, 200*time.Millisecond)
+	collector.RowsFailed(3)
+	collector.ErrorFileBuilt(time.Second)
+	collector.TaskEnded(core.TaskStatusFinished, time.Minute)
+	collector.TaskEnded(core.TaskStatusFinished, time.Minute)
+	collector.TaskEnded(core.TaskStatusCancelled, time.Second)
+
+	counters := []struct {
+		name      string
+		collector prom.Collector
+		want      float64
+	}{
+		{"rows_read_total", collector.rowsRead, 15},
+		{"rows_submitted_total", collector.rowsSubmitted, 12},
+		{"rows_failed_total", collector.rowsFailed, 3},
+		{"tasks_total{status=finished}", collector.tasks.WithLabelValues(string(core.TaskStatusFinished)), 2},
+		{"tasks_total{status=cancelled}", collector.tasks.WithLabelValues(string(core.TaskStatusCancelled)), 1},
+		{"tasks_total{status=failed}", collector.tasks.WithLabelValues(string(core.TaskStatusFailed)), 0},
+	}
+	for _, tt := range counters {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testutil.ToFloat64(tt.collector); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	histograms := []struct {
+		name      string
+		collector prom.Collector
+		want      int
+	}{
+		{"submit_duration_seconds", collector.submitDuration, 1},
+		{"file_open_duration_seconds", collector.fileOpenDuration, 1},
+		{"error_file_build_duration_seconds", collector.errFileBuildDuration, 1},
+		{"task_duration_seconds", collector.taskDuration, 2}, // finished 和 cancelled 两个状态
+	}
+	for _, tt := range histograms {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testutil.CollectAndCount(tt.collector); got != tt.want {
+				t.Errorf("got %d series, want %d", got, tt.want)
+			}
+		})
+	}
+
+	// 注册表中的提交耗时包含两次观测
+	expected := `
+# HELP importkit_submit_duration_seconds Latency of a single Submit call.
+# TYPE importkit_submit_duration_seconds histogram
+importkit_submit_duration_seconds_bucket{le="0.005"} 0
+importkit_submit_duration_seconds_bucket{le="0.01"} 0
+importkit_submit_duration_seconds_bucket{le="0.025"} 0
+importkit_submit_duration_seconds_bucket{le="0.05"} 0
+importkit_submit_duration_seconds_bucket{le="0.1"} 1
+importkit_submit_duration_seconds_bucket{le="0.25"} 2
+importkit_submit_duration_seconds_bucket{le="0.5"} 2
+importkit_submit_duration_seconds_bucket{le="1"} 2
+importkit_submit_duration_seconds_bucket{le="2.5"} 2
+importkit_submit_duration_seconds_bucket{le="5"} 2
+importkit_submit_duration_seconds_bucket{le="10"} 2
+importkit_submit_duration_seconds_bucket{le="+Inf"} 2
+importkit_submit_duration_seconds_sum 0.30000000000000004
+importkit_submit_duration_seconds_count 2
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "importkit_submit_duration_seconds"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCollectorNamespace(t *testing.T) {
+	tests := []struct {
+		namespace string
+		want      string
+	}{
+		{"", "importkit_rows_read_total"},
+		{"crm", "crm_rows_read_total"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			registry := prom.NewRegistry()
+			registry.MustRegister(NewCollector(tt.namespace))
+			count, err := testutil.GatherAndCount(registry, tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != 1 {
+				t.Errorf("got %d series of %s, want 1", count, tt.want)
+			}
+		})
+	}
+}
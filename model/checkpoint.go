@@ -0,0 +1,34 @@
+package model
+
+// Checkpoint 导入任务的检查点，任务中断后重新运行时跳过已经完成的行
+type Checkpoint struct {
+	DoneCount int               // 完成行数
+	Sheets    []SheetCheckpoint // 每个工作表的检查点，顺序与任务中工作表的顺序一致
+}
+
+// SheetCheckpoint 工作表的检查点
+type SheetCheckpoint struct {
+	Sheet       string            // 工作表名称
+	FormIndex   int               // 水位线：小于等于该行索引的行都已经完成（已提交或已写入错误）
+	DoneIndexes []int             // 大于水位线但已经完成的行索引，唯一列分组提交时可能出现
+	Errors      []CheckpointError // 已完成行的错误，恢复后会写入错误文件。保存时只包含上次保存之后新增的错误，存储需要追加
+}
+
+// CheckpointError 检查点中的错误行
+type CheckpointError struct {
+	RowIndex int                   // 行索引
+	Cells    []string              // 原始行数据
+	Message  string                // 错误消息，为空时表示分组中没有错误的行
+	Columns  map[int]string        // 单元格错误的列索引和错误消息，用于在错误文件中标记单元格
+	Items    []CheckpointErrorItem // 错误报告中的错误，恢复后会写入错误报告
+}
+
+// CheckpointErrorItem 检查点中错误行的结构化错误
+type CheckpointErrorItem struct {
+	Code    string            // 错误码
+	Field   string            // 字段名称
+	Header  string            // 列表头
+	Cell    string            // 单元格坐标
+	Message string            // 错误消息
+	Params  map[string]string // 错误消息的参数
+}
@@ -2,6 +2,7 @@ package importkit
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 
 	"github.com/nuominmin/import-kit/model"
@@ -17,46 +18,167 @@ type IImportImplementor interface {
 	Submit(rows core.IRows, task *model.Task)
 }
 
+// IContextSubmitter 需要上下文的实现者可以实现该接口，导入任务将调用 SubmitWithContext 代替 Submit
+type IContextSubmitter interface {
+	// SubmitWithContext 提交数据，上下文被取消时应尽快返回
+	SubmitWithContext(ctx context.Context, rows core.IRows, task *model.Task)
+}
+
+// IImportValidator 试运行时实现者可以实现该接口校验数据，例如检查数据是否已经存在，不应该写入数据
+type IImportValidator interface {
+	// Validate 校验数据，通过 IRow.SetErrs 设置错误
+	Validate(ctx context.Context, rows core.IRows, task *model.Task)
+}
+
 // TaskContainer 导入任务服容器
 // 常驻内存
 type TaskContainer interface {
 	// NewImportTask 一个新的导入任务
 	NewImportTask(ctx context.Context, taskId uint64, implementor IImportImplementor, skipRowNum int) (core.TaskScheduler, error)
+	// NewMultiSheetImportTask 一个新的多工作表导入任务，implementor 处理主工作表，sheets 为其它工作表。
+	// 所有工作表在同一个任务中按顺序处理，错误文件中每个工作表对应一个错误工作表，返回的 SheetScheduler 与 sheets 一一对应
+	NewMultiSheetImportTask(ctx context.Context, taskId uint64, implementor IImportImplementor, skipRowNum int, sheets ...ImportSheet) (core.TaskScheduler, []core.SheetScheduler, error)
 	// NewImportCheckTask 一个新的导入检查任务
 	NewImportCheckTask(openTplFileFunc, openImportFileFUnc core.OpenFileFunc, skipRowNum int, maxRowNum int64) core.ICheckService
 }
 
+// ImportSheet 导入任务中主工作表之外的工作表，每个工作表有自己的中转结构体、头部行数量和实现者。
+// 泛型实现者使用 NewTypedImportSheet 创建
+type ImportSheet struct {
+	Sheet       core.SheetSelector // 工作表，例如 core.SheetByName("明细")
+	Implementor IImportImplementor // 实现者
+	SkipRowNum  int                // 头部行数量
+}
+
 type container struct {
 	dependency dependency.Container
+	logger     core.Logger
+	metrics    core.Metrics
 }
 
-// importTask 每次都是一个新的任务
-type importTask struct {
-	ctx         context.Context      // 上下文
-	task        *model.Task          // 导入任务数据
-	dependency  dependency.Container // 外部依赖接口
-	implementor IImportImplementor   // 实现类
+// Option 导入任务服务容器的选项
+type Option func(*container)
+
+// WithLogger 设置日志，与 *slog.Logger 兼容。每条日志会附带任务id和导入中心id
+func WithLogger(logger core.Logger) Option {
+	return func(s *container) {
+		s.logger = logger
+	}
+}
+
+// WithMetrics 设置指标统计，例如 metrics/prometheus 中的 Collector
+func WithMetrics(metrics core.Metrics) Option {
+	return func(s *container) {
+		s.metrics = metrics
+	}
 }
 
 // NewService .
-func NewService(dependency dependency.Container) TaskContainer {
-	return &container{
+func NewService(dependency dependency.Container, opts ...Option) TaskContainer {
+	s := &container{
 		dependency: dependency,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *container) NewImportTask(ctx context.Context, taskId uint64, implementor IImportImplementor, skipRowNum int) (core.TaskScheduler, error) {
+	scheduler, _, err := s.newImportTask(ctx, taskId, implementor, skipRowNum)
+	return scheduler, err
+}
+
+func (s *container) NewMultiSheetImportTask(ctx context.Context, taskId uint64, implementor IImportImplementor, skipRowNum int, sheets ...ImportSheet) (core.TaskScheduler, []core.SheetScheduler, error) {
+	scheduler, task, err := s.newImportTask(ctx, taskId, implementor, skipRowNum)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sheetSchedulers := make([]core.SheetScheduler, 0, len(sheets))
+	for _, sheet := range sheets {
+		sheetSchedulers = append(sheetSchedulers, scheduler.AddSheet(sheet.Sheet, &importTask{
+			ctx:         ctx,
+			task:        task,
+			dependency:  s.dependency,
+			implementor: sheet.Implementor,
+		}, sheet.SkipRowNum))
+	}
+	return scheduler, sheetSchedulers, nil
+}
+
+// 创建导入任务，返回导入任务数据用于添加其它工作表
+func (s *container) newImportTask(ctx context.Context, taskId uint64, implementor IImportImplementor, skipRowNum int) (core.TaskScheduler, *model.Task, error) {
 	task, err := s.dependency.GetTaskById(ctx, taskId)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return core.NewImportService(&importTask{
+	scheduler := core.NewImportService(&importTask{
 		ctx:         ctx,
 		task:        task,
 		dependency:  s.dependency,
 		implementor: implementor,
-	}, skipRowNum), nil
+	}, skipRowNum)
+	scheduler.SetContext(ctx)
+	scheduler.SetLogger(s.logger, "task_id", taskId, "import_id", task.ImportId)
+	scheduler.SetMetrics(s.metrics)
+
+	// 任务参数中指定了语言时使用该语言的错误消息
+	if locale := taskLocale(task); locale != "" {
+		scheduler.SetLocale(locale)
+	}
+
+	// 依赖容器实现了检查点存储时支持断点续传
+	if store, ok := s.dependency.(dependency.CheckpointStore); ok {
+		scheduler.SetCheckpointStore(&checkpointStore{
+			ctx:    ctx,
+			taskId: taskId,
+			store:  store,
+		})
+	}
+	return scheduler, task, nil
+}
+
+// 任务参数中的语言，任务参数为 JSON，例如 {"locale": "en-US"}，不是 JSON 或者没有指定时返回空
+func taskLocale(task *model.Task) core.Locale {
+	var params struct {
+		Locale string `json:"locale"`
+	}
+	if task.Params == "" || json.Unmarshal([]byte(task.Params), &params) != nil {
+		return ""
+	}
+	return core.Locale(params.Locale)
+}
+
+// checkpointStore 按任务id读写依赖容器中的检查点
+type checkpointStore struct {
+	ctx    context.Context
+	taskId uint64
+	store  dependency.CheckpointStore
+}
+
+// Load 加载检查点
+func (cs *checkpointStore) Load() (*model.Checkpoint, error) {
+	return cs.store.GetCheckpoint(cs.ctx, cs.taskId)
+}
+
+// Save 保存检查点，任务被取消时仍然需要保存
+func (cs *checkpointStore) Save(checkpoint *model.Checkpoint) error {
+	return cs.store.SaveCheckpoint(context.WithoutCancel(cs.ctx), cs.taskId, checkpoint)
+}
+
+// Delete 删除检查点
+func (cs *checkpointStore) Delete() error {
+	return cs.store.DeleteCheckpoint(context.WithoutCancel(cs.ctx), cs.taskId)
+}
+
+// importTask 每次都是一个新的任务
+type importTask struct {
+	ctx         context.Context      // 上下文
+	task        *model.Task          // 导入任务数据
+	dependency  dependency.Container // 外部依赖接口
+	implementor IImportImplementor   // 实现类
 }
 
 // TransferStruct 传输结构
@@ -64,11 +186,27 @@ func (it *importTask) TransferStruct() interface{} {
 	return it.implementor.TransferStruct()
 }
 
-// Submit 调用实现者的 Submit 方法
+// Submit 提交数据，使用创建任务时的上下文
 func (it *importTask) Submit(rows core.IRows) {
+	it.SubmitWithContext(it.ctx, rows)
+}
+
+// SubmitWithContext 调用实现者的 Submit 方法，实现者实现了 IContextSubmitter 时调用 SubmitWithContext
+func (it *importTask) SubmitWithContext(ctx context.Context, rows core.IRows) {
+	if submitter, ok := it.implementor.(IContextSubmitter); ok {
+		submitter.SubmitWithContext(ctx, rows, it.task)
+		return
+	}
 	it.implementor.Submit(rows, it.task)
 }
 
+// Validate 试运行时调用实现者的 Validate 方法，实现者没有实现 IImportValidator 时只进行解析和 tag 校验
+func (it *importTask) Validate(ctx context.Context, rows core.IRows) {
+	if validator, ok := it.implementor.(IImportValidator); ok {
+		validator.Validate(ctx, rows, it.task)
+	}
+}
+
 // OpenFile 打开文件
 func (it *importTask) OpenFile() core.OpenFileFunc {
 	return func() (*core.File, error) {
@@ -76,7 +214,7 @@ func (it *importTask) OpenFile() core.OpenFileFunc {
 		if err != nil {
 			return nil, err
 		}
-		return core.OpenBytesFile(content)
+		return core.OpenBytesAutoFile(content)
 	}
 }
 
@@ -92,33 +230,123 @@ func (it *importTask) Progress() (doneInterval int, fn func(total, doneCount int
 	}
 }
 
-// End 任务结束, 更新成功状态 or 上传错误文件并更新错误文件ID和失败状态
+// End 任务结束，结束状态为已完成
 func (it *importTask) End(errs core.IErrorMessages, doneCount int, errorCount int) error {
+	return it.EndWithStatus(errs, doneCount, errorCount, core.TaskStatusFinished)
+}
+
+// EndWithStatus 任务结束, 更新成功状态 or 上传错误文件并更新错误文件ID和失败状态，存在错误行时上传错误报告（如果依赖容器支持）。
+// 任务被取消时上传已处理行的错误文件（如果有）并更新取消状态（依赖容器没有实现 TaskCanceller 时更新失败状态），试运行时上传错误文件（如果有）并更新试运行完成状态（依赖容器没有实现 TaskValidator 时与正常结束一样，没有错误行时更新成功状态，否则上传错误文件并更新失败状态）
+func (it *importTask) EndWithStatus(errs core.IErrorMessages, doneCount int, errorCount int, status core.TaskStatus) error {
+	// 任务被取消时 it.ctx 已经不可用，结束状态仍然需要更新
+	ctx := context.WithoutCancel(it.ctx)
+
+	if err := it.uploadErrReport(ctx, errs, errorCount); err != nil {
+		return err
+	}
+
+	if status == core.TaskStatusDryRun {
+		validator, ok := it.dependency.(dependency.TaskValidator)
+		if !ok && errorCount == 0 {
+			return it.dependency.TaskSucceed(ctx, it.task.ImportId)
+		}
+		errFileId, err := it.uploadErrFile(ctx, errs)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			if err = it.dependency.TaskFailed(ctx, it.task.ImportId, errFileId); err != nil {
+				return fmt.Errorf("[TaskFailed] error: %v", err)
+			}
+			return nil
+		}
+		if err = validator.TaskValidated(ctx, it.task.ImportId, errFileId); err != nil {
+			return fmt.Errorf("[TaskValidated] error: %v", err)
+		}
+		return nil
+	}
+
+	if status == core.TaskStatusCancelled {
+		errFileId, err := it.uploadErrFile(ctx, errs)
+		if err != nil {
+			return err
+		}
+		canceller, ok := it.dependency.(dependency.TaskCanceller)
+		if !ok {
+			if err = it.dependency.TaskFailed(ctx, it.task.ImportId, errFileId); err != nil {
+				return fmt.Errorf("[TaskFailed] error: %v", err)
+			}
+			return nil
+		}
+		if err = canceller.TaskCancelled(ctx, it.task.ImportId, errFileId); err != nil {
+			return fmt.Errorf("[TaskCancelled] error: %v", err)
+		}
+		return nil
+	}
+
 	if errorCount == 0 {
-		return it.dependency.TaskSucceed(it.ctx, it.task.ImportId)
+		return it.dependency.TaskSucceed(ctx, it.task.ImportId)
+	}
+
+	if errs.GetErrFile() == nil {
+		return nil
+	}
+
+	errFileId, err := it.uploadErrFile(ctx, errs)
+	if err != nil {
+		return err
 	}
 
+	if err = it.dependency.TaskFailed(ctx, it.task.ImportId, errFileId); err != nil {
+		return fmt.Errorf("[TaskFailed] error: %v", err)
+	}
+	return nil
+}
+
+// Failed 任务失败，没有错误文件
+func (it *importTask) Failed(err error) error {
+	if err1 := it.dependency.TaskFailed(context.WithoutCancel(it.ctx), it.task.ImportId, 0); err1 != nil {
+		return fmt.Errorf("[TaskFailed] error: %v, cause: %v", err1, err)
+	}
+	return nil
+}
+
+// 上传错误文件，不存在错误文件时返回 0
+func (it *importTask) uploadErrFile(ctx context.Context, errs core.IErrorMessages) (errFileId uint64, err error) {
 	errFile := errs.GetErrFile()
 	if errFile == nil {
-		return nil
+		return 0, nil
 	}
 
 	buffer, err := errFile.WriteToBuffer()
 	if err != nil {
-		return fmt.Errorf("[WriteToBuffer] error: %v", err)
+		return 0, fmt.Errorf("[WriteToBuffer] error: %v", err)
 	}
 
-	var errFileId uint64
-	if errFileId, err = it.dependency.UploadFile(it.ctx, buffer.Bytes()); err != nil {
-		return fmt.Errorf("[UploadFile] error: %v", err)
+	if errFileId, err = it.dependency.UploadFile(ctx, buffer.Bytes()); err != nil {
+		return 0, fmt.Errorf("[UploadFile] error: %v", err)
 	}
+	return errFileId, nil
+}
 
-	if err = it.dependency.TaskFailed(it.ctx, it.task.ImportId, errFileId); err != nil {
-		return fmt.Errorf("[TaskFailed] error: %v", err)
+// 依赖容器实现了 ErrorReportUploader 时上传错误报告，没有错误行时不上传
+func (it *importTask) uploadErrReport(ctx context.Context, errs core.IErrorMessages, errorCount int) error {
+	uploader, ok := it.dependency.(dependency.ErrorReportUploader)
+	if !ok || errorCount == 0 {
+		return nil
+	}
+
+	report, err := errs.GetReport().JSON()
+	if err != nil {
+		return fmt.Errorf("[ErrorReport] error: %v", err)
+	}
+
+	if err = uploader.UploadErrorReport(ctx, it.task.ImportId, report); err != nil {
+		return fmt.Errorf("[UploadErrorReport] error: %v", err)
 	}
 	return nil
 }
 
 func (s *container) NewImportCheckTask(openTplFileFunc, openImportFileFUnc core.OpenFileFunc, skipRowNum int, maxRowNum int64) core.ICheckService {
-	return core.NewCheckService(openTplFileFunc, openImportFileFUnc, skipRowNum, maxRowNum)
+	return core.NewCheckService(openTplFileFunc, openImportFileFUnc, skipRowNum, maxRowNum).SetLogger(s.logger)
 }
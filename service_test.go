@@ -0,0 +1,389 @@
+package importkit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/nuominmin/import-kit/core"
+	"github.com/nuominmin/import-kit/dependency"
+	"github.com/nuominmin/import-kit/model"
+)
+
+// 测试用的依赖容器，记录任务状态的更新和上传的文件
+type testContainer struct {
+	task    *model.Task
+	content []byte // 导入文件内容
+
+	mu       sync.Mutex
+	calls    []string // 任务状态的更新，例如 "TaskFailed 1"
+	uploaded [][]byte // 上传的文件，文件id为索引加 1
+}
+
+func (c *testContainer) record(format string, args ...interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, fmt.Sprintf(format, args...))
+}
+
+func (c *testContainer) GetTaskById(ctx context.Context, taskId uint64) (*model.Task, error) {
+	if c.task == nil || c.task.ImportId != taskId {
+		return nil, errors.New("task not found")
+	}
+	return c.task, nil
+}
+
+func (c *testContainer) DownloadFile(ctx context.Context, fileId uint64) ([]byte, error) {
+	return c.content, nil
+}
+
+func (c *testContainer) UploadFile(ctx context.Context, content []byte) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.uploaded = append(c.uploaded, content)
+	return uint64(len(c.uploaded)), nil
+}
+
+func (c *testContainer) TaskStart(ctx context.Context, taskId uint64) error {
+	c.record("TaskStart")
+	return nil
+}
+
+func (c *testContainer) TaskProgressUpdate(ctx context.Context, taskId uint64, total, done int) error {
+	return nil
+}
+
+func (c *testContainer) TaskSucceed(ctx context.Context, taskId uint64) error {
+	c.record("TaskSucceed")
+	return nil
+}
+
+func (c *testContainer) TaskFailed(ctx context.Context, taskId, errFileId uint64) error {
+	c.record("TaskFailed %d", errFileId)
+	return nil
+}
+
+// 实现了 TaskValidator 的依赖容器
+type testValidatorContainer struct {
+	*testContainer
+}
+
+func (c *testValidatorContainer) TaskValidated(ctx context.Context, taskId, errFileId uint64) error {
+	c.record("TaskValidated %d", errFileId)
+	return nil
+}
+
+// 实现了 TaskCanceller 的依赖容器
+type testCancellerContainer struct {
+	*testContainer
+}
+
+func (c *testCancellerContainer) TaskCancelled(ctx context.Context, taskId, errFileId uint64) error {
+	c.record("TaskCancelled %d", errFileId)
+	return nil
+}
+
+// 实现了 CheckpointStore 的依赖容器，记录调用时上下文是否已经取消
+type testCheckpointContainer struct {
+	*testContainer
+	checkpoint *model.Checkpoint
+}
+
+func (c *testCheckpointContainer) GetCheckpoint(ctx context.Context, taskId uint64) (*model.Checkpoint, error) {
+	c.record("GetCheckpoint %d %v", taskId, ctx.Err() != nil)
+	return c.checkpoint, nil
+}
+
+func (c *testCheckpointContainer) SaveCheckpoint(ctx context.Context, taskId uint64, checkpoint *model.Checkpoint) error {
+	c.record("SaveCheckpoint %d %v", taskId, ctx.Err() != nil)
+	c.checkpoint = checkpoint
+	return nil
+}
+
+func (c *testCheckpointContainer) DeleteCheckpoint(ctx context.Context, taskId uint64) error {
+	c.record("DeleteCheckpoint %d %v", taskId, ctx.Err() != nil)
+	c.checkpoint = nil
+	return nil
+}
+
+// 实现了 ErrorReportUploader 的依赖容器
+type testReportContainer struct {
+	*testContainer
+	reports [][]byte
+}
+
+func (c *testReportContainer) UploadErrorReport(ctx context.Context, taskId uint64, report []byte) error {
+	c.record("UploadErrorReport %d", taskId)
+	c.reports = append(c.reports, report)
+	return nil
+}
+
+// 测试用的中转结构体
+type testContent struct {
+	Name string
+	Qty  int64
+}
+
+// 测试用的实现者，数量为负数时设置行错误
+type testImportImplementor struct {
+	cancel func() // 不为空时提交后调用，用于取消任务
+
+	mu        sync.Mutex
+	submitted []string
+	validated []string
+}
+
+func (p *testImportImplementor) TransferStruct() interface{} {
+	return &testContent{}
+}
+
+func (p *testImportImplementor) Submit(rows core.IRows, task *model.Task) {
+	names := checkTestRows(rows)
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.submitted = append(p.submitted, names...)
+}
+
+func (p *testImportImplementor) Validate(ctx context.Context, rows core.IRows, task *model.Task) {
+	names := checkTestRows(rows)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.validated = append(p.validated, names...)
+}
+
+// 校验行数据，返回行的名称
+func checkTestRows(rows core.IRows) []string {
+	var names []string
+	rows.Each(func(i int, row core.IRow) bool {
+		data := row.GetData().(*testContent)
+		names = append(names, data.Name)
+		if data.Qty < 0 {
+			row.SetErrs(fmt.Errorf("negative %d", data.Qty))
+		}
+		return false
+	})
+	return names
+}
+
+// 新建测试用的依赖容器，导入文件为 csv
+func newTestContainer(content string) *testContainer {
+	return &testContainer{
+		task:    &model.Task{ImportId: 1, FileId: 10},
+		content: []byte(content),
+	}
+}
+
+// 新建不输出日志的服务
+func newTestService(c dependency.Container) TaskContainer {
+	return NewService(c, WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+}
+
+func TestDryRunService(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		validator bool
+		calls     []string
+		uploaded  int
+	}{
+		{"no validator without errors", "名称,数量\nk1,1\nk2,2\n", false, []string{"TaskStart", "TaskSucceed"}, 0},
+		{"no validator with errors", "名称,数量\nk1,1\nk2,-2\n", false, []string{"TaskStart", "TaskFailed 1"}, 1},
+		{"validator without errors", "名称,数量\nk1,1\nk2,2\n", true, []string{"TaskStart", "TaskValidated 0"}, 0},
+		{"validator with errors", "名称,数量\nk1,1\nk2,-2\n", true, []string{"TaskStart", "TaskValidated 1"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestContainer(tt.content)
+			var dc dependency.Container = c
+			if tt.validator {
+				dc = &testValidatorContainer{c}
+			}
+			implementor := &testImportImplementor{}
+			task, err := newTestService(dc).NewImportTask(context.Background(), 1, implementor, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			task.SetDryRun(true)
+			if err = task.Run(); err != nil {
+				t.Fatal(err)
+			}
+
+			// 试运行时调用 Validate 代替 Submit
+			if len(implementor.submitted) != 0 {
+				t.Errorf("submitted got %v, want none", implementor.submitted)
+			}
+			validated := append([]string(nil), implementor.validated...)
+			sort.Strings(validated)
+			if want := []string{"k1", "k2"}; !reflect.DeepEqual(validated, want) {
+				t.Errorf("validated got %v, want %v", validated, want)
+			}
+			if !reflect.DeepEqual(c.calls, tt.calls) {
+				t.Errorf("calls got %v, want %v", c.calls, tt.calls)
+			}
+			if len(c.uploaded) != tt.uploaded {
+				t.Errorf("uploaded got %d files, want %d", len(c.uploaded), tt.uploaded)
+			}
+		})
+	}
+}
+
+func TestEndWithStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		cancel    bool
+		canceller bool
+		calls     []string
+		submitted []string
+	}{
+		{"finished", "名称,数量\nk1,1\nk2,2\n", false, false, []string{"TaskStart", "TaskSucceed"}, []string{"k1", "k2"}},
+		{"finished with errors", "名称,数量\nk1,1\nk2,-2\n", false, false, []string{"TaskStart", "TaskFailed 1"}, []string{"k1", "k2"}},
+		{"cancelled without canceller", "名称,数量\nk1,-1\nk2,2\n", true, false, []string{"TaskStart", "TaskFailed 1"}, []string{"k1"}},
+		{"cancelled", "名称,数量\nk1,-1\nk2,2\n", true, true, []string{"TaskStart", "TaskCancelled 1"}, []string{"k1"}},
+		{"cancelled without errors", "名称,数量\nk1,1\nk2,2\n", true, true, []string{"TaskStart", "TaskCancelled 0"}, []string{"k1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestContainer(tt.content)
+			var dc dependency.Container = c
+			if tt.canceller {
+				dc = &testCancellerContainer{c}
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			// 第一行提交后取消任务，第二行不再提交
+			implementor := &testImportImplementor{}
+			if tt.cancel {
+				implementor.cancel = cancel
+			}
+			task, err := newTestService(dc).NewImportTask(ctx, 1, implementor, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 任务被取消时返回上下文的错误
+			if err = task.Run(); tt.cancel && !errors.Is(err, context.Canceled) || !tt.cancel && err != nil {
+				t.Fatalf("got error %v, want cancelled %v", err, tt.cancel)
+			}
+
+			if !reflect.DeepEqual(implementor.submitted, tt.submitted) {
+				t.Errorf("submitted got %v, want %v", implementor.submitted, tt.submitted)
+			}
+			if !reflect.DeepEqual(c.calls, tt.calls) {
+				t.Errorf("calls got %v, want %v", c.calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestTaskLocale(t *testing.T) {
+	tests := []struct {
+		params string
+		want   core.Locale
+	}{
+		{"", ""},
+		{"not json", ""},
+		{`{"other": 1}`, ""},
+		{`{"locale": "en-US"}`, core.LocaleEnUS},
+		{`{"locale": "zh-CN", "other": 1}`, core.LocaleZhCN},
+	}
+	for _, tt := range tests {
+		if got := taskLocale(&model.Task{Params: tt.params}); got != tt.want {
+			t.Errorf("params %q got %q, want %q", tt.params, got, tt.want)
+		}
+	}
+}
+
+func TestCheckpointStore(t *testing.T) {
+	c := &testCheckpointContainer{testContainer: newTestContainer("名称,数量\nk1,1\nk2,2\n")}
+
+	// 任务被取消后仍然可以保存和删除检查点，加载使用原上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	store := &checkpointStore{ctx: ctx, taskId: 1, store: c}
+	checkpoint := &model.Checkpoint{DoneCount: 1}
+	if err := store.Save(checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := store.Load(); err != nil || got != checkpoint {
+		t.Fatalf("load got %v, %v, want %v", got, err, checkpoint)
+	}
+	if err := store.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"SaveCheckpoint 1 false", "GetCheckpoint 1 true", "DeleteCheckpoint 1 false"}
+	if !reflect.DeepEqual(c.calls, want) {
+		t.Errorf("calls got %v, want %v", c.calls, want)
+	}
+
+	// 依赖容器实现了 CheckpointStore 时导入任务加载检查点，完成后删除
+	c.calls = nil
+	task, err := newTestService(c).NewImportTask(context.Background(), 1, &testImportImplementor{}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = task.Run(); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"TaskStart", "GetCheckpoint 1 false", "TaskSucceed", "DeleteCheckpoint 1 false"}
+	if !reflect.DeepEqual(c.calls, want) {
+		t.Errorf("calls got %v, want %v", c.calls, want)
+	}
+}
+
+func TestUploadErrReport(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		calls   []string
+		report  *core.ErrorReport
+	}{
+		{"without errors", "名称,数量\nk1,1\nk2,2\n", []string{"TaskStart", "TaskSucceed"}, nil},
+		{"with errors", "名称,数量\nk1,1\nk2,-2\n", []string{"TaskStart", "UploadErrorReport 1", "TaskFailed 1"}, &core.ErrorReport{
+			Total:  1,
+			Counts: map[string]int{core.ErrorCodeUnknown: 1},
+			Items:  []core.ErrorReportItem{{Sheet: "Sheet1", Row: 3, Code: core.ErrorCodeUnknown, Message: "negative -2"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &testReportContainer{testContainer: newTestContainer(tt.content)}
+			task, err := newTestService(c).NewImportTask(context.Background(), 1, &testImportImplementor{}, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = task.Run(); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(c.calls, tt.calls) {
+				t.Errorf("calls got %v, want %v", c.calls, tt.calls)
+			}
+			if tt.report == nil {
+				if len(c.reports) != 0 {
+					t.Errorf("reports got %d, want none", len(c.reports))
+				}
+				return
+			}
+			if len(c.reports) != 1 {
+				t.Fatalf("reports got %d, want 1", len(c.reports))
+			}
+			var report core.ErrorReport
+			if err = json.Unmarshal(c.reports[0], &report); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(&report, tt.report) {
+				t.Errorf("report got %+v, want %+v", report, *tt.report)
+			}
+		})
+	}
+}
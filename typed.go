@@ -0,0 +1,134 @@
+package importkit
+
+import (
+	"context"
+
+	"github.com/nuominmin/import-kit/core"
+	"github.com/nuominmin/import-kit/model"
+)
+
+// TypedImplementor 泛型实现者，T 为对应excel导入模板的中转结构体，不需要实现 TransferStruct 和类型断言
+type TypedImplementor[T any] interface {
+	// Submit 提交数据
+	Submit(rows TypedRows[T], task *model.Task)
+}
+
+// TypedContextSubmitter 需要上下文的泛型实现者可以实现该接口，导入任务将调用 SubmitWithContext 代替 Submit
+type TypedContextSubmitter[T any] interface {
+	// SubmitWithContext 提交数据，上下文被取消时应尽快返回
+	SubmitWithContext(ctx context.Context, rows TypedRows[T], task *model.Task)
+}
+
+// TypedValidator 试运行时泛型实现者可以实现该接口校验数据，不应该写入数据
+type TypedValidator[T any] interface {
+	// Validate 校验数据，通过 TypedRow.SetErrs 设置错误
+	Validate(ctx context.Context, rows TypedRows[T], task *model.Task)
+}
+
+// NewTypedImportTask 一个新的泛型导入任务，T 为中转结构体，行数据为 *T
+func NewTypedImportTask[T any](ctx context.Context, tc TaskContainer, taskId uint64, implementor TypedImplementor[T], skipRowNum int) (core.TaskScheduler, error) {
+	return tc.NewImportTask(ctx, taskId, &typedImplementor[T]{implementor: implementor}, skipRowNum)
+}
+
+// NewTypedImportSheet 多工作表导入任务中使用泛型实现者的工作表，T 为该工作表的中转结构体
+func NewTypedImportSheet[T any](sheet core.SheetSelector, implementor TypedImplementor[T], skipRowNum int) ImportSheet {
+	return ImportSheet{
+		Sheet:       sheet,
+		Implementor: &typedImplementor[T]{implementor: implementor},
+		SkipRowNum:  skipRowNum,
+	}
+}
+
+// typedImplementor 把泛型实现者适配为 IImportImplementor
+type typedImplementor[T any] struct {
+	implementor TypedImplementor[T]
+}
+
+// TransferStruct 中转结构体
+func (ti *typedImplementor[T]) TransferStruct() interface{} {
+	return new(T)
+}
+
+// Submit 提交数据
+func (ti *typedImplementor[T]) Submit(rows core.IRows, task *model.Task) {
+	ti.implementor.Submit(TypedRows[T]{rows: rows}, task)
+}
+
+// SubmitWithContext 实现者实现了 TypedContextSubmitter 时调用 SubmitWithContext，否则调用 Submit
+func (ti *typedImplementor[T]) SubmitWithContext(ctx context.Context, rows core.IRows, task *model.Task) {
+	if submitter, ok := ti.implementor.(TypedContextSubmitter[T]); ok {
+		submitter.SubmitWithContext(ctx, TypedRows[T]{rows: rows}, task)
+		return
+	}
+	ti.Submit(rows, task)
+}
+
+// Validate 实现者实现了 TypedValidator 时调用 Validate
+func (ti *typedImplementor[T]) Validate(ctx context.Context, rows core.IRows, task *model.Task) {
+	if validator, ok := ti.implementor.(TypedValidator[T]); ok {
+		validator.Validate(ctx, TypedRows[T]{rows: rows}, task)
+	}
+}
+
+// TypedRows 泛型的行数据集合
+type TypedRows[T any] struct {
+	rows core.IRows
+}
+
+// TypedEachFn 泛型的行数据遍历函数
+type TypedEachFn[T any] func(i int, row TypedRow[T]) (isBreak bool)
+
+// Count rows 的长度
+func (rs TypedRows[T]) Count() int {
+	return rs.rows.Count()
+}
+
+// SetRowsErrs 设置所有行相同的错误
+func (rs TypedRows[T]) SetRowsErrs(errs ...error) {
+	rs.rows.SetRowsErrs(errs...)
+}
+
+// Each 每一个数据
+func (rs TypedRows[T]) Each(fn TypedEachFn[T]) {
+	rs.rows.Each(func(i int, row core.IRow) bool {
+		return fn(i, TypedRow[T]{IRow: row})
+	})
+}
+
+// EachReverse 逆序循环每一个数据
+func (rs TypedRows[T]) EachReverse(fn TypedEachFn[T]) {
+	rs.rows.EachReverse(func(i int, row core.IRow) bool {
+		return fn(i, TypedRow[T]{IRow: row})
+	})
+}
+
+// GetRow 获取行
+func (rs TypedRows[T]) GetRow(i int) TypedRow[T] {
+	return TypedRow[T]{IRow: rs.rows.GetRow(i)}
+}
+
+// GetFirstRow 获取第一行
+func (rs TypedRows[T]) GetFirstRow() TypedRow[T] {
+	return TypedRow[T]{IRow: rs.rows.GetFirstRow()}
+}
+
+// IsErr 是否错误
+func (rs TypedRows[T]) IsErr() bool {
+	return rs.rows.IsErr()
+}
+
+// Untyped 获取原始的 core.IRows
+func (rs TypedRows[T]) Untyped() core.IRows {
+	return rs.rows
+}
+
+// TypedRow 泛型的行数据
+type TypedRow[T any] struct {
+	core.IRow
+}
+
+// Data 获取行数据，空行返回 nil
+func (r TypedRow[T]) Data() *T {
+	data, _ := r.GetData().(*T)
+	return data
+}
@@ -0,0 +1,154 @@
+package importkit
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/nuominmin/import-kit/core"
+	"github.com/nuominmin/import-kit/model"
+	"github.com/xuri/excelize/v2"
+)
+
+// 测试用的泛型实现者，数量为负数时设置行错误
+type typedService struct {
+	mu        sync.Mutex
+	submitted []string
+	validated []string
+}
+
+func (p *typedService) Submit(rows TypedRows[testContent], task *model.Task) {
+	p.check(&p.submitted, rows)
+}
+
+func (p *typedService) Validate(ctx context.Context, rows TypedRows[testContent], task *model.Task) {
+	p.check(&p.validated, rows)
+}
+
+// 校验行数据，记录行的名称
+func (p *typedService) check(names *[]string, rows TypedRows[testContent]) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	rows.Each(func(i int, row TypedRow[testContent]) bool {
+		data := row.Data()
+		*names = append(*names, data.Name)
+		if data.Qty < 0 {
+			row.SetErrs(fmt.Errorf("negative %d", data.Qty))
+		}
+		return false
+	})
+}
+
+type typedContextKey struct{}
+
+// 需要上下文的泛型实现者，记录提交时上下文中的值
+type typedContextService struct {
+	typedService
+	values []interface{}
+}
+
+func (p *typedContextService) SubmitWithContext(ctx context.Context, rows TypedRows[testContent], task *model.Task) {
+	p.values = append(p.values, ctx.Value(typedContextKey{}))
+	p.check(&p.submitted, rows)
+}
+
+func TestTypedImportTask(t *testing.T) {
+	tests := []struct {
+		name      string
+		dryRun    bool
+		context   bool
+		submitted []string
+		validated []string
+	}{
+		{"submit", false, false, []string{"k1", "k2"}, nil},
+		{"submit with context", false, true, []string{"k1", "k2"}, nil},
+		{"dry run", true, false, nil, []string{"k1", "k2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestContainer("名称,数量\nk1,1\nk2,-2\n")
+			ctx := context.WithValue(context.Background(), typedContextKey{}, "v")
+			service := &typedContextService{}
+			var implementor TypedImplementor[testContent] = &service.typedService
+			if tt.context {
+				implementor = service
+			}
+			task, err := NewTypedImportTask[testContent](ctx, newTestService(c), 1, implementor, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			task.SetDryRun(tt.dryRun)
+			if err = task.Run(); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(service.submitted, tt.submitted) {
+				t.Errorf("submitted got %v, want %v", service.submitted, tt.submitted)
+			}
+			if !reflect.DeepEqual(service.validated, tt.validated) {
+				t.Errorf("validated got %v, want %v", service.validated, tt.validated)
+			}
+			// 实现了 TypedContextSubmitter 时使用创建任务时的上下文提交
+			if tt.context {
+				if want := []interface{}{"v", "v"}; !reflect.DeepEqual(service.values, want) {
+					t.Errorf("context values got %v, want %v", service.values, want)
+				}
+			}
+			// 第 2 行的错误通过 TypedRow.SetErrs 写入错误文件
+			if want := []string{"TaskStart", "TaskFailed 1"}; !reflect.DeepEqual(c.calls, want) {
+				t.Errorf("calls got %v, want %v", c.calls, want)
+			}
+		})
+	}
+}
+
+func TestTypedImportSheet(t *testing.T) {
+	f := excelize.NewFile()
+	if _, err := f.NewSheet("明细"); err != nil {
+		t.Fatal(err)
+	}
+	for sheet, rows := range map[string][][]interface{}{
+		"Sheet1": {{"名称", "数量"}, {"k1", 1}},
+		"明细":     {{"名称", "数量"}, {"d1", 5}, {"d2", 6}},
+	} {
+		for i, row := range rows {
+			cell, _ := excelize.CoordinatesToCellName(1, i+1)
+			if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	buffer, err := f.WriteToBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestContainer("")
+	c.content = buffer.Bytes()
+	master, detail := &testImportImplementor{}, &typedService{}
+	task, sheets, err := newTestService(c).NewMultiSheetImportTask(context.Background(), 1, master, 1,
+		NewTypedImportSheet[testContent](core.SheetByName("明细"), detail, 1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sheets) != 1 {
+		t.Fatalf("sheets got %d, want 1", len(sheets))
+	}
+	if err = task.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"k1"}; !reflect.DeepEqual(master.submitted, want) {
+		t.Errorf("master submitted got %v, want %v", master.submitted, want)
+	}
+	if want := []string{"d1", "d2"}; !reflect.DeepEqual(detail.submitted, want) {
+		t.Errorf("detail submitted got %v, want %v", detail.submitted, want)
+	}
+	if want := []string{"TaskStart", "TaskSucceed"}; !reflect.DeepEqual(c.calls, want) {
+		t.Errorf("calls got %v, want %v", c.calls, want)
+	}
+}